@@ -0,0 +1,60 @@
+package doris
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type accessLogConfig struct {
+	sampling float64
+	slow     time.Duration
+	exclude  []string
+}
+
+func (cnf *accessLogConfig) excluded(path string) bool {
+	for _, exclude := range cnf.exclude {
+		if path == exclude || strings.HasSuffix(exclude, "/") && strings.HasPrefix(path, exclude) {
+			return true
+		}
+	}
+	return false
+}
+
+func accessLogMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	cnf := sp.accessLog
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cnf.excluded(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := trackResponse(w)
+		next.ServeHTTP(rw, r)
+		latency := time.Since(start)
+
+		level := slog.LevelInfo
+		switch {
+		case cnf.slow > 0 && latency >= cnf.slow:
+			level = slog.LevelWarn
+		case rw.Status() >= http.StatusInternalServerError:
+			level = slog.LevelWarn
+		case rw.Status() < http.StatusBadRequest && cnf.sampling < 1 && rand.Float64() >= cnf.sampling:
+			// Only successful requests are sampled, failures are always logged.
+			return
+		}
+
+		logger(r.Context()).LogAttrs(r.Context(), level, "HTTP request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", rt.pattern),
+			slog.Int("status", rw.Status()),
+			slog.Int64("size", rw.bytes),
+			slog.Duration("latency", latency),
+			slog.String("client-ip", ClientIP(r.Context())),
+			slog.String("user-agent", r.UserAgent()))
+	})
+}
@@ -0,0 +1,113 @@
+package doris
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordsHandler struct {
+	records *[]slog.Record
+}
+
+func (h recordsHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h recordsHandler) Handle(ctx context.Context, record slog.Record) error {
+	*h.records = append(*h.records, record)
+	return nil
+}
+
+func (h recordsHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+func (h recordsHandler) WithGroup(string) slog.Handler { return h }
+
+// captureLogs replaces the default logger during the test to inspect the records.
+func captureLogs(t *testing.T) *[]slog.Record {
+	records := new([]slog.Record)
+	prev := slog.Default()
+	slog.SetDefault(slog.New(recordsHandler{records}))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return records
+}
+
+func TestAccessLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		status   int
+		delay    time.Duration
+		sampling float64
+		logged   bool
+		level    slog.Level
+	}{
+		{name: "success", path: "/foo", status: http.StatusOK, sampling: 1, logged: true, level: slog.LevelInfo},
+		{name: "client error", path: "/foo", status: http.StatusNotFound, sampling: 1, logged: true, level: slog.LevelInfo},
+		{name: "server error", path: "/foo", status: http.StatusInternalServerError, sampling: 1, logged: true, level: slog.LevelWarn},
+		{name: "slow", path: "/foo", status: http.StatusOK, delay: 20 * time.Millisecond, sampling: 1, logged: true, level: slog.LevelWarn},
+		{name: "sampled out", path: "/foo", status: http.StatusOK, sampling: 0},
+		{name: "failure not sampled", path: "/foo", status: http.StatusBadRequest, sampling: 0, logged: true, level: slog.LevelInfo},
+		{name: "slow not sampled", path: "/foo", status: http.StatusOK, delay: 20 * time.Millisecond, sampling: 0, logged: true, level: slog.LevelWarn},
+		{name: "health", path: "/health", status: http.StatusOK, sampling: 1},
+		{name: "metrics", path: "/metrics", status: http.StatusOK, sampling: 1},
+		{name: "excluded prefix", path: "/internal/foo", status: http.StatusOK, sampling: 1},
+		{name: "excluded exact path only", path: "/healthz", status: http.StatusOK, sampling: 1, logged: true, level: slog.LevelInfo},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records := captureLogs(t)
+			sp := &ServerPort{
+				accessLog: &accessLogConfig{
+					sampling: test.sampling,
+					slow:     10 * time.Millisecond,
+					exclude:  []string{"/health", "/metrics", "/internal/"},
+				},
+			}
+			handler := accessLogMiddleware(sp, &route{pattern: "/foo"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(test.delay)
+				w.WriteHeader(test.status)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			if !test.logged {
+				require.Empty(t, *records)
+				return
+			}
+			require.Len(t, *records, 1)
+			require.Equal(t, test.level, (*records)[0].Level)
+		})
+	}
+}
+
+func TestAccessLogRoutes(t *testing.T) {
+	records := captureLogs(t)
+	server := NewServer()
+	server.Get("/foo", func(w http.ResponseWriter, r *http.Request) error {
+		return NotFound("foo not found")
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.NotEmpty(t, w.Header().Get(RequestIDHeader))
+
+	var found bool
+	for _, record := range *records {
+		if record.Message != "HTTP request" {
+			continue
+		}
+		found = true
+		attrs := make(map[string]slog.Value)
+		record.Attrs(func(attr slog.Attr) bool {
+			attrs[attr.Key] = attr.Value
+			return true
+		})
+		require.Equal(t, "/foo", attrs["route"].String())
+		require.EqualValues(t, http.StatusNotFound, attrs["status"].Int64())
+	}
+	require.True(t, found)
+}
@@ -2,6 +2,8 @@ package doris
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
 	"connectrpc.com/connect"
 	"github.com/altipla-consulting/errors"
@@ -16,13 +18,19 @@ type ConnectHub struct {
 	r            *Router
 	cors         []string
 	interceptors []connect.Interceptor
+	timeouts     map[string]time.Duration
+	maxBodySize  map[string]int64
+	critical     map[string]bool
 }
 
 // NewConnectHub creates a new hub prepared to mount Connect APIs.
 func NewConnectHub(r *Router, opts ...ConnectHubOption) *ConnectHub {
 	hub := &ConnectHub{
-		r:    r,
-		cors: []string{"https://studio.buf.build"},
+		r:           r,
+		cors:        []string{"https://studio.buf.build"},
+		timeouts:    make(map[string]time.Duration),
+		maxBodySize: make(map[string]int64),
+		critical:    make(map[string]bool),
 	}
 	for _, opt := range opts {
 		opt(hub)
@@ -37,6 +45,10 @@ type MountFn func(opts ...connect.HandlerOption) (string, http.Handler)
 // Mount a new API.
 func (hub *ConnectHub) Mount(fn MountFn) {
 	pattern, handler := fn(hub.opts()...)
+	handler = hub.limitBodies(handler)
+	if hub.r.prefix != "" {
+		handler = http.StripPrefix(hub.r.prefix, handler)
+	}
 	if len(hub.cors) > 0 {
 		cnf := cors.Options{
 			AllowedOrigins: hub.cors,
@@ -57,15 +69,64 @@ func (hub *ConnectHub) Mount(fn MountFn) {
 		}
 		handler = cors.New(cnf).Handler(handler)
 	}
-	hub.r.PathPrefixHandlerHTTP(pattern, handler)
+
+	// Timeouts and body limits are applied by the hub to honor the custom ones of each procedure.
+	hub.r.PathPrefixHandlerHTTP(pattern, handler, WithRouteTimeout(0), WithRouteMaxBodySize(0), connectRoute(), hub.criticalRoute())
+}
+
+func (hub *ConnectHub) criticalRoute() RouteOption {
+	return func(rt *route) {
+		rt.critical = func(r *http.Request) bool {
+			return hub.critical[strings.TrimPrefix(r.URL.Path, hub.r.prefix)]
+		}
+	}
+}
+
+func (hub *ConnectHub) limitBodies(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := hub.r.port.maxBodySize
+		if custom, ok := hub.maxBodySize[r.URL.Path]; ok {
+			limit = custom
+		}
+		if limit > 0 {
+			var ok bool
+			r, ok = limitBody(w, r, limit)
+			if !ok {
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
 }
 
 func (hub *ConnectHub) opts() []connect.HandlerOption {
-	return []connect.HandlerOption{
-		connect.WithInterceptors(ServerInterceptors()...),
+	opts := []connect.HandlerOption{
+		connect.WithInterceptors(serverInterceptors(hub.r.port.timeout, hub.timeouts)...),
+		connect.WithInterceptors(maxMessageSizeInterceptor(hub.r.port.maxBodySize, hub.maxBodySize)),
 		connect.WithInterceptors(hub.interceptors...),
 		connect.WithCodec(new(codecJSON)),
 	}
+	if limit := hub.readMaxBytes(); limit > 0 {
+		opts = append(opts, connect.WithReadMaxBytes(int(limit)))
+	}
+	return opts
+}
+
+// readMaxBytes returns the biggest limit of all the procedures to limit the size of
+// the decompressed messages, as the option is shared by all the handlers of the hub.
+// The exact limit of each one is enforced over the body and the decoded message.
+func (hub *ConnectHub) readMaxBytes() int64 {
+	limit := hub.r.port.maxBodySize
+	if limit <= 0 {
+		return 0
+	}
+	for _, custom := range hub.maxBodySize {
+		if custom <= 0 {
+			return 0
+		}
+		limit = max(limit, custom)
+	}
+	return limit
 }
 
 // ConnectHubOption configures the Connect hub.
@@ -86,6 +147,35 @@ func WithInterceptors(interceptors ...connect.Interceptor) ConnectHubOption {
 	}
 }
 
+// WithProcedureTimeout overrides the default timeout of the server for a single
+// procedure, for example "/acme.service.v1.FooService/Export". A zero timeout disables
+// it completely for long running calls. Streaming procedures do not have the default
+// timeout of the server; they are only limited if they have a custom one.
+func WithProcedureTimeout(procedure string, timeout time.Duration) ConnectHubOption {
+	return func(cnf *ConnectHub) {
+		cnf.timeouts[procedure] = timeout
+	}
+}
+
+// WithProcedureMaxBodySize overrides the default maximum size of the request bodies
+// of the server for a single procedure. A zero size disables the limit. The limit
+// applies to the body as sent and to the message once decompressed.
+func WithProcedureMaxBodySize(procedure string, size int64) ConnectHubOption {
+	return func(cnf *ConnectHub) {
+		cnf.maxBodySize[procedure] = size
+	}
+}
+
+// WithCriticalProcedures marks procedures like "/foo.v1.FooService/Bar" as critical
+// so they are never rejected when the server sheds load. See WithLoadShedding.
+func WithCriticalProcedures(procedures ...string) ConnectHubOption {
+	return func(cnf *ConnectHub) {
+		for _, procedure := range procedures {
+			cnf.critical[procedure] = true
+		}
+	}
+}
+
 // Deprecated: Use NewConnectHub instead.
 type RegisterFn func() (pattern string, handler http.Handler)
 
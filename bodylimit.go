@@ -0,0 +1,97 @@
+package doris
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/altipla-consulting/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// DefaultMaxBodySize is the maximum size of the request bodies if no other limit
+// is configured.
+const DefaultMaxBodySize = 32 << 20
+
+func bodyLimitMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	if rt.maxBodySize <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, ok := limitBody(w, r, rt.maxBodySize)
+		if !ok {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitBody rejects the request if the body declares a size bigger than the limit,
+// or limits the body while reading it otherwise. It returns false if the request
+// was rejected.
+func limitBody(w http.ResponseWriter, r *http.Request, limit int64) (*http.Request, bool) {
+	pattern := routeFromContext(r.Context()).pattern
+	if r.ContentLength > limit {
+		countBodyTooLarge(pattern)
+		rejectRequest(w, r, http.StatusRequestEntityTooLarge, connect.CodeResourceExhausted)
+		return nil, false
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return r, true
+	}
+
+	// Shallow copy of the request to avoid replacing the body of the caller.
+	limited := new(http.Request)
+	*limited = *r
+	limited.Body = &limitedBody{
+		ReadCloser: http.MaxBytesReader(w, r.Body, limit),
+		pattern:    pattern,
+	}
+	return limited, true
+}
+
+// limitedBody counts the requests that exceed the limit while reading the body.
+type limitedBody struct {
+	io.ReadCloser
+	pattern  string
+	exceeded bool
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if maxErr := new(http.MaxBytesError); !body.exceeded && errors.As(err, &maxErr) {
+		body.exceeded = true
+		countBodyTooLarge(body.pattern)
+	}
+	return n, err
+}
+
+// maxMessageSizeInterceptor enforces the limit of each procedure over the decoded
+// messages. The handlers of a hub share a single limit of the decompressed size,
+// the biggest of all of them, so compressed bodies could expand over the limit of
+// the procedure otherwise.
+func maxMessageSizeInterceptor(limit int64, procedures map[string]int64) connect.Interceptor {
+	return connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return connect.UnaryFunc(func(ctx context.Context, in connect.AnyRequest) (connect.AnyResponse, error) {
+			procedure := in.Spec().Procedure
+			size := limit
+			if custom, ok := procedures[procedure]; ok {
+				size = custom
+			}
+			if size > 0 {
+				if msg, ok := in.Any().(proto.Message); ok && int64(proto.Size(msg)) > size {
+					countBodyTooLarge(procedure)
+					return nil, connect.NewError(connect.CodeResourceExhausted, errors.Errorf("request message larger than %d bytes", size))
+				}
+			}
+			return next(ctx, in)
+		})
+	})
+}
+
+func countBodyTooLarge(pattern string) {
+	metrics.GetOrCreateCounter(fmt.Sprintf(`doris_http_body_too_large_total{route=%q}`, pattern)).Inc()
+}
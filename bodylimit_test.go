@@ -0,0 +1,113 @@
+package doris
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestBodyLimitContentLength(t *testing.T) {
+	var called bool
+	handler := bodyLimitMiddleware(nil, &route{maxBodySize: 10}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 20))))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Contains(t, w.Body.String(), "Petición demasiado grande")
+	require.False(t, called)
+}
+
+func TestBodyLimitStreaming(t *testing.T) {
+	var readErr error
+	handler := bodyLimitMiddleware(nil, &route{maxBodySize: 10}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 20)))
+	r.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	maxErr := new(http.MaxBytesError)
+	require.True(t, errors.As(readErr, &maxErr))
+	require.EqualValues(t, 10, maxErr.Limit)
+}
+
+func TestBodyLimitDisabled(t *testing.T) {
+	var body []byte
+	handler := bodyLimitMiddleware(nil, &route{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("x", 20))))
+	require.Len(t, body, 20)
+}
+
+func echoProcedure(ctx context.Context, in *connect.Request[wrapperspb.StringValue]) (*connect.Response[wrapperspb.StringValue], error) {
+	return connect.NewResponse(in.Msg), nil
+}
+
+func TestMaxMessageSizeInterceptor(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int64
+		procedures map[string]int64
+		value      string
+		code       connect.Code
+	}{
+		{name: "below limit", limit: 100, value: "foo"},
+		{name: "above limit", limit: 10, value: strings.Repeat("x", 20), code: connect.CodeResourceExhausted},
+		{
+			name:       "procedure limit",
+			limit:      100,
+			procedures: map[string]int64{"/test.Echo/Echo": 10},
+			value:      strings.Repeat("x", 20),
+			code:       connect.CodeResourceExhausted,
+		},
+		{
+			name:       "procedure disabled",
+			limit:      10,
+			procedures: map[string]int64{"/test.Echo/Echo": 0},
+			value:      strings.Repeat("x", 20),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interceptor := maxMessageSizeInterceptor(test.limit, test.procedures)
+			web := httptest.NewServer(connect.NewUnaryHandler("/test.Echo/Echo", echoProcedure, connect.WithInterceptors(interceptor)))
+			defer web.Close()
+
+			client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](web.Client(), web.URL+"/test.Echo/Echo")
+			_, err := client.CallUnary(context.Background(), connect.NewRequest(wrapperspb.String(test.value)))
+			if test.code == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Equal(t, test.code, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestBodyLimitRoutes(t *testing.T) {
+	server := NewServer(WithMaxBodySize(4))
+	server.Post("/submit", func(w http.ResponseWriter, r *http.Request) error {
+		_, err := io.ReadAll(r.Body)
+		return err
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader("foo")))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader("foo bar")))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
@@ -0,0 +1,402 @@
+package doris
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/altipla-consulting/errors"
+	"github.com/altipla-consulting/telemetry"
+	"golang.org/x/sync/singleflight"
+)
+
+// CacheOption configures the response cache middleware.
+type CacheOption func(cnf *cacheConfig)
+
+// WithCacheMaxSize configures the maximum size in bytes of the responses kept in the
+// cache. The least recently used ones are removed first. By default it is 64 MB.
+func WithCacheMaxSize(size int64) CacheOption {
+	return func(cnf *cacheConfig) {
+		cnf.maxSize = size
+	}
+}
+
+// WithCacheQueryParams configures the only query parameters that are part of the
+// key of the cache. The rest are ignored, for example tracking parameters like
+// utm_source. By default all the query parameters are part of the key.
+func WithCacheQueryParams(params ...string) CacheOption {
+	return func(cnf *cacheConfig) {
+		cnf.params = params
+	}
+}
+
+type cacheConfig struct {
+	maxSize int64
+	params  []string
+}
+
+// Cache returns a middleware that keeps in memory the responses that handlers mark
+// as public with the Cache-Control header, using s-maxage or max-age as the time
+// they are fresh and stale-while-revalidate as the time they can be served while
+// they are refreshed in the background.
+//
+// Responses are keyed by method, host, path, query parameters and the request headers
+// listed in their Vary header. Concurrent requests of the same missing response are
+// coalesced in a single call to the handler. Responses are buffered completely, so
+// it should not be used with streaming routes.
+//
+// Responses that read the CSPNonce or the CSRFToken of the request are never stored,
+// as every client would receive the nonce or token of the first one otherwise. Add
+// Cache before SecurityHeaders and CSRF to cache the pages that do not use them.
+func Cache(opts ...CacheOption) func(http.Handler) http.Handler {
+	cnf := &cacheConfig{
+		maxSize: 64 << 20,
+	}
+	for _, opt := range opts {
+		opt(cnf)
+	}
+	c := &responseCache{
+		cnf:     cnf,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.serve(w, r, next)
+		})
+	}
+}
+
+type responseCache struct {
+	cnf *cacheConfig
+	grp singleflight.Group
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+	size    int64
+}
+
+type cacheEntry struct {
+	key string
+
+	// Only in the entries of the base key that list the headers of the Vary header.
+	vary []string
+
+	status int
+	header http.Header
+	body   []byte
+	stored time.Time
+	fresh  time.Duration
+	stale  time.Duration
+}
+
+func (entry *cacheEntry) size() int64 {
+	size := len(entry.key) + len(entry.body)
+	for _, name := range entry.vary {
+		size += len(name)
+	}
+	for name, values := range entry.header {
+		size += len(name)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	return int64(size)
+}
+
+func (c *responseCache) serve(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	pattern := routeFromContext(r.Context()).pattern
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Authorization") != "" {
+		countCache(pattern, "bypass")
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	base := c.baseKey(r)
+	key := base
+	if marker := c.get(base); marker != nil && marker.vary != nil {
+		key = varyKey(base, marker.vary, r)
+	}
+
+	if entry := c.get(key); entry != nil {
+		age := time.Since(entry.stored)
+		if age < entry.fresh {
+			countCache(pattern, "hit")
+			writeCached(w, r, entry, age, "HIT")
+			return
+		}
+		if age < entry.fresh+entry.stale {
+			countCache(pattern, "stale")
+			c.revalidate(key, base, r, next)
+			writeCached(w, r, entry, age, "STALE")
+			return
+		}
+	}
+
+	countCache(pattern, "miss")
+	entry, shared := c.fillShared(key, base, r, next)
+	if shared && entry.key != "" && key == base {
+		// The Vary header of the response was not known before the leader filled it,
+		// so it may have been a different variant than the one of this request.
+		if marker := c.get(base); marker != nil && marker.vary != nil {
+			if variant := varyKey(base, marker.vary, r); variant != entry.key {
+				entry, shared = c.fillShared(variant, base, r, next)
+			}
+		}
+	}
+	if shared && entry.key == "" {
+		// Responses that cannot be cached may be specific to the client that made
+		// the request, so they are never shared with the rest.
+		next.ServeHTTP(w, r)
+		return
+	}
+	writeCached(w, r, entry, 0, "MISS")
+}
+
+// fillShared coalesces the concurrent requests of the same key in a single call to
+// the handler. It returns true if the entry was filled by another request.
+func (c *responseCache) fillShared(key, base string, r *http.Request, next http.Handler) (*cacheEntry, bool) {
+	var leader bool
+	result, _, _ := c.grp.Do(key, func() (any, error) {
+		leader = true
+		return c.fill(base, r, next), nil
+	})
+	return result.(*cacheEntry), !leader
+}
+
+// revalidate refreshes the entry in the background while the stale one is served.
+func (c *responseCache) revalidate(key, base string, r *http.Request, next http.Handler) {
+	r = r.Clone(context.WithoutCancel(r.Context()))
+	go func() {
+		defer func() {
+			if rec := errors.Recover(recover()); rec != nil {
+				telemetry.ReportError(r.Context(), rec)
+			}
+		}()
+		_, _, _ = c.grp.Do(key, func() (any, error) {
+			return c.fill(base, r, next), nil
+		})
+	}()
+}
+
+// fill runs the handler and stores the response if it can be cached. The key of
+// the returned entry is empty if it was not stored.
+func (c *responseCache) fill(base string, r *http.Request, next http.Handler) *cacheEntry {
+	if r.Method == http.MethodHead {
+		// Store the body to serve the GET requests with the same entry.
+		r = r.Clone(r.Context())
+		r.Method = http.MethodGet
+	}
+
+	private := new(atomic.Bool)
+	r = r.WithContext(context.WithValue(r.Context(), cachePrivateKey{}, private))
+	rec := &cacheRecorder{header: make(http.Header)}
+	next.ServeHTTP(rec, r)
+
+	entry := &cacheEntry{
+		status: rec.status,
+		header: rec.header,
+		body:   rec.body.Bytes(),
+		stored: time.Now(),
+	}
+	if entry.status == 0 {
+		entry.status = http.StatusOK
+	}
+	if private.Load() || !cacheableResponse(entry) {
+		return entry
+	}
+
+	var vary []string
+	for _, value := range entry.header.Values("Vary") {
+		for _, name := range splitHeaderList(value) {
+			vary = append(vary, http.CanonicalHeaderKey(name))
+		}
+	}
+	if slices.Contains(vary, "*") {
+		return entry
+	}
+
+	entry.key = base
+	if len(vary) > 0 {
+		slices.Sort(vary)
+		c.put(&cacheEntry{key: base, vary: vary, stored: entry.stored, fresh: entry.fresh, stale: entry.stale})
+		entry.key = varyKey(base, vary, r)
+	}
+	c.put(entry)
+	return entry
+}
+
+type cachePrivateKey struct{}
+
+// preventCache marks the response of the request as specific to the client, for
+// example because it contains the CSP nonce or the CSRF token of the request.
+func preventCache(ctx context.Context) {
+	if private, ok := ctx.Value(cachePrivateKey{}).(*atomic.Bool); ok {
+		private.Store(true)
+	}
+}
+
+// cacheableResponse checks the status and headers of the response, and fills the
+// times it can be served from the Cache-Control header.
+func cacheableResponse(entry *cacheEntry) bool {
+	switch entry.status {
+	case http.StatusOK, http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+	default:
+		return false
+	}
+	if entry.header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	var public bool
+	var maxAge, sMaxAge time.Duration
+	var hasSMaxAge bool
+	for _, directive := range splitHeaderList(entry.header.Get("Cache-Control")) {
+		name, value, _ := strings.Cut(directive, "=")
+		seconds, _ := strconv.Atoi(strings.Trim(value, `"`))
+		switch strings.ToLower(name) {
+		case "public":
+			public = true
+		case "private", "no-store", "no-cache":
+			return false
+		case "max-age":
+			maxAge = time.Duration(seconds) * time.Second
+		case "s-maxage":
+			sMaxAge, hasSMaxAge = time.Duration(seconds)*time.Second, true
+		case "stale-while-revalidate":
+			entry.stale = time.Duration(seconds) * time.Second
+		}
+	}
+	entry.fresh = maxAge
+	if hasSMaxAge {
+		entry.fresh = sMaxAge
+	}
+	return public && entry.fresh > 0
+}
+
+func (c *responseCache) baseKey(r *http.Request) string {
+	query := r.URL.Query()
+	if c.cnf.params != nil {
+		selected := make(url.Values)
+		for _, param := range c.cnf.params {
+			if values, ok := query[param]; ok {
+				selected[param] = values
+			}
+		}
+		query = selected
+	}
+	host := RequestHost(r.Context())
+	if host == "" {
+		host = r.Host
+	}
+	// HEAD requests are served with the entries of GET ones.
+	return host + r.URL.Path + "?" + query.Encode()
+}
+
+func varyKey(base string, vary []string, r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(base)
+	for _, name := range vary {
+		sb.WriteString("\n")
+		sb.WriteString(name)
+		sb.WriteString(": ")
+		sb.WriteString(strings.Join(r.Header.Values(name), ", "))
+	}
+	return sb.String()
+}
+
+func (c *responseCache) get(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Since(entry.stored) > entry.fresh+entry.stale {
+		c.remove(elem)
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	return entry
+}
+
+func (c *responseCache) put(entry *cacheEntry) {
+	size := entry.size()
+	if size > c.cnf.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.remove(elem)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.size += size
+	for c.size > c.cnf.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *responseCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size()
+}
+
+func writeCached(w http.ResponseWriter, r *http.Request, entry *cacheEntry, age time.Duration, result string) {
+	for name, values := range entry.header {
+		w.Header()[name] = slices.Clone(values)
+	}
+	if entry.key != "" {
+		w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+		w.Header().Set("X-Cache", result)
+	}
+	w.WriteHeader(entry.status)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(entry.body)
+	}
+}
+
+func countCache(pattern, result string) {
+	metrics.GetOrCreateCounter(fmt.Sprintf(`doris_http_cache_requests_total{route=%q,result=%q}`, pattern, result)).Inc()
+}
+
+// cacheRecorder buffers the response of the handler to store it in the cache.
+type cacheRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *cacheRecorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *cacheRecorder) WriteHeader(status int) {
+	if rec.status == 0 && status >= 200 {
+		rec.status = status
+	}
+}
+
+func (rec *cacheRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
@@ -0,0 +1,169 @@
+package doris
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func serveCache(handler http.Handler, target string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for i := 0; i < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestCache(t *testing.T) {
+	var calls atomic.Int32
+	handler := Cache()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		_, _ = io.WriteString(w, "hello")
+	}))
+
+	w := serveCache(handler, "/foo?a=1")
+	require.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	require.Equal(t, "hello", w.Body.String())
+
+	w = serveCache(handler, "/foo?a=1")
+	require.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	require.Equal(t, "hello", w.Body.String())
+	require.EqualValues(t, 1, calls.Load())
+
+	serveCache(handler, "/foo?a=2")
+	require.EqualValues(t, 2, calls.Load())
+}
+
+func TestCacheNotPublic(t *testing.T) {
+	var calls atomic.Int32
+	handler := Cache()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "private, max-age=60")
+	}))
+
+	serveCache(handler, "/")
+	w := serveCache(handler, "/")
+	require.Empty(t, w.Header().Get("X-Cache"))
+	require.EqualValues(t, 2, calls.Load())
+}
+
+func TestCacheQueryParams(t *testing.T) {
+	var calls atomic.Int32
+	handler := Cache(WithCacheQueryParams("page"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "public, max-age=60")
+	}))
+
+	serveCache(handler, "/?page=1&utm_source=foo")
+	serveCache(handler, "/?page=1&utm_source=bar")
+	require.EqualValues(t, 1, calls.Load())
+}
+
+func TestCacheVary(t *testing.T) {
+	handler := Cache()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = io.WriteString(w, r.Header.Get("Accept-Language"))
+	}))
+
+	require.Equal(t, "es", serveCache(handler, "/", "Accept-Language", "es").Body.String())
+	require.Equal(t, "en", serveCache(handler, "/", "Accept-Language", "en").Body.String())
+
+	w := serveCache(handler, "/", "Accept-Language", "es")
+	require.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	require.Equal(t, "es", w.Body.String())
+}
+
+func TestCacheCoalesce(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	handler := Cache()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("Cache-Control", "public, max-age=60")
+	}))
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveCache(handler, "/")
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	require.EqualValues(t, 1, calls.Load())
+}
+
+func TestCacheEviction(t *testing.T) {
+	var calls atomic.Int32
+	handler := Cache(WithCacheMaxSize(1500))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		_, _ = w.Write(make([]byte, 1000))
+	}))
+
+	serveCache(handler, "/a")
+	serveCache(handler, "/b")
+	serveCache(handler, "/a")
+	require.EqualValues(t, 3, calls.Load())
+}
+
+func TestCacheCoalesceVary(t *testing.T) {
+	release := make(chan struct{})
+	handler := Cache()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		_, _ = io.WriteString(w, r.Header.Get("Accept-Language"))
+	}))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 6)
+	for i := range bodies {
+		lang := "es"
+		if i%2 == 1 {
+			lang = "en"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies[i] = lang + "=" + serveCache(handler, "/", "Accept-Language", lang).Body.String()
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, body := range bodies {
+		lang, served, _ := strings.Cut(body, "=")
+		require.Equal(t, lang, served)
+	}
+}
+
+func TestCachePrivate(t *testing.T) {
+	var calls atomic.Int32
+	handler := Cache()(SecurityHeaders()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		_, _ = io.WriteString(w, CSPNonce(r.Context()))
+	})))
+
+	first := serveCache(handler, "/")
+	second := serveCache(handler, "/")
+	require.EqualValues(t, 2, calls.Load())
+	require.NotEqual(t, first.Body.String(), second.Body.String())
+	require.Empty(t, second.Header().Get("X-Cache"))
+}
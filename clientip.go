@@ -0,0 +1,210 @@
+package doris
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type clientInfo struct {
+	ip     string
+	scheme string
+	host   string
+}
+
+type clientInfoKey struct{}
+
+// ClientIP returns the IP of the client that sent the request. When the request
+// comes from a trusted proxy the forwarding headers are used to know the real one.
+// It returns an empty string outside a request handled by doris.
+func ClientIP(ctx context.Context) string {
+	if info, ok := ctx.Value(clientInfoKey{}).(*clientInfo); ok {
+		return info.ip
+	}
+	return ""
+}
+
+// RequestScheme returns the scheme (http or https) the client used to connect to
+// the application, even if a trusted proxy terminated TLS in front of it.
+// It returns an empty string outside a request handled by doris.
+func RequestScheme(ctx context.Context) string {
+	if info, ok := ctx.Value(clientInfoKey{}).(*clientInfo); ok {
+		return info.scheme
+	}
+	return ""
+}
+
+// RequestHost returns the host the client used to connect to the application,
+// even if a trusted proxy rewrote it in front of it.
+// It returns an empty string outside a request handled by doris.
+func RequestHost(ctx context.Context) string {
+	if info, ok := ctx.Value(clientInfoKey{}).(*clientInfo); ok {
+		return info.host
+	}
+	return ""
+}
+
+// ForwardedHeader is the header the trusted proxies write with the address of the
+// client. See WithForwardedHeader.
+type ForwardedHeader string
+
+const (
+	// ForwardedHeaderXForwardedFor reads the de facto X-Forwarded-For header, with
+	// the X-Forwarded-Proto and X-Forwarded-Host ones. It is the default one.
+	ForwardedHeaderXForwardedFor ForwardedHeader = "X-Forwarded-For"
+
+	// ForwardedHeaderForwarded reads the standard Forwarded header of RFC 7239.
+	ForwardedHeaderForwarded ForwardedHeader = "Forwarded"
+)
+
+func withClientInfo(r *http.Request, trusted []netip.Prefix, header ForwardedHeader) *http.Request {
+	info := resolveClientInfo(r, trusted, header)
+	return r.WithContext(context.WithValue(r.Context(), clientInfoKey{}, info))
+}
+
+func resolveClientInfo(r *http.Request, trusted []netip.Prefix, header ForwardedHeader) *clientInfo {
+	info := &clientInfo{
+		scheme: "http",
+		host:   r.Host,
+	}
+	if r.TLS != nil {
+		info.scheme = "https"
+	}
+
+	peer, ok := parseHopAddr(r.RemoteAddr)
+	if !ok {
+		info.ip = r.RemoteAddr
+		return info
+	}
+	info.ip = peer.String()
+	if !containsAddr(trusted, peer) {
+		return info
+	}
+
+	// Walk the chain of proxies from the nearest one to the farthest and stop at
+	// the first address we do not trust. That is the real client.
+	hops := forwardedHops(r.Header, header)
+	client := peer
+	idx := -1
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !containsAddr(trusted, client) {
+			break
+		}
+		addr, ok := parseHopAddr(hops[i].addr)
+		if !ok {
+			break
+		}
+		client = addr
+		idx = i
+	}
+	info.ip = client.String()
+
+	if header == ForwardedHeaderForwarded {
+		if idx >= 0 && hops[idx].proto != "" {
+			info.scheme = strings.ToLower(hops[idx].proto)
+		}
+		if idx >= 0 && hops[idx].host != "" {
+			info.host = hops[idx].host
+		}
+		return info
+	}
+	if proto := hopHeaderValue(r.Header, "X-Forwarded-Proto", len(hops), idx); proto != "" {
+		info.scheme = strings.ToLower(proto)
+	}
+	if host := hopHeaderValue(r.Header, "X-Forwarded-Host", len(hops), idx); host != "" {
+		info.host = host
+	}
+
+	return info
+}
+
+type forwardedHop struct {
+	addr  string
+	proto string
+	host  string
+}
+
+// forwardedHops extracts the list of addresses from the header written by the
+// proxies. The other one is ignored, as the proxies pass it through untouched and it
+// would be completely controlled by the client.
+func forwardedHops(header http.Header, name ForwardedHeader) []forwardedHop {
+	var hops []forwardedHop
+	if name == ForwardedHeaderForwarded {
+		for _, value := range header.Values("Forwarded") {
+			for _, element := range splitHeaderList(value) {
+				var hop forwardedHop
+				for _, pair := range strings.Split(element, ";") {
+					k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if !ok {
+						continue
+					}
+					v = strings.Trim(v, `"`)
+					switch strings.ToLower(k) {
+					case "for":
+						hop.addr = v
+					case "proto":
+						hop.proto = v
+					case "host":
+						hop.host = v
+					}
+				}
+				hops = append(hops, hop)
+			}
+		}
+		return hops
+	}
+
+	for _, value := range header.Values("X-Forwarded-For") {
+		for _, addr := range splitHeaderList(value) {
+			hops = append(hops, forwardedHop{addr: addr})
+		}
+	}
+	return hops
+}
+
+// parseHopAddr parses the address of a hop that may optionally contain a port
+// and brackets around IPv6 addresses.
+func parseHopAddr(addr string) (netip.Addr, bool) {
+	if ip, err := netip.ParseAddr(addr); err == nil {
+		return ip.Unmap(), true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
+
+func splitHeaderList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// hopHeaderValue returns the value of a X-Forwarded-* header that belongs to the hop
+// of the client. When the proxies append a value for every hop it is the one with the
+// same index; otherwise it is the last one, written by the nearest trusted proxy. The
+// first values are controlled by the client and cannot be trusted.
+func hopHeaderValue(header http.Header, name string, hops, idx int) string {
+	var items []string
+	for _, value := range header.Values(name) {
+		items = append(items, splitHeaderList(value)...)
+	}
+	if len(items) == 0 {
+		return ""
+	}
+	if idx >= 0 && len(items) == hops {
+		return items[idx]
+	}
+	return items[len(items)-1]
+}
@@ -0,0 +1,114 @@
+package doris
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveClientInfoUntrustedPeer(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://example.com/", nil)
+	r.RemoteAddr = "203.0.113.7:4000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	r.Header.Set("X-Forwarded-Proto", "https")
+
+	info := resolveClientInfo(r, parsePrefixes([]string{"10.0.0.0/8"}), ForwardedHeaderXForwardedFor)
+	require.Equal(t, "203.0.113.7", info.ip)
+	require.Equal(t, "http", info.scheme)
+	require.Equal(t, "example.com", info.host)
+}
+
+func TestResolveClientInfoXForwardedFor(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://internal/", nil)
+	r.RemoteAddr = "10.0.0.2:4000"
+	r.Header.Set("X-Forwarded-For", "1.2.3.4, 198.51.100.1, 10.0.0.1")
+	r.Header.Set("X-Forwarded-Proto", "https")
+	r.Header.Set("X-Forwarded-Host", "www.example.com")
+
+	info := resolveClientInfo(r, parsePrefixes([]string{"10.0.0.0/8"}), ForwardedHeaderXForwardedFor)
+	require.Equal(t, "198.51.100.1", info.ip)
+	require.Equal(t, "https", info.scheme)
+	require.Equal(t, "www.example.com", info.host)
+}
+
+func TestResolveClientInfoForwarded(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://internal/", nil)
+	r.RemoteAddr = "10.0.0.2:4000"
+	r.Header.Set("Forwarded", `for="[2001:db8::1]:4711";proto=https;host=www.example.com, for=10.0.0.1`)
+
+	info := resolveClientInfo(r, parsePrefixes([]string{"10.0.0.0/8"}), ForwardedHeaderForwarded)
+	require.Equal(t, "2001:db8::1", info.ip)
+	require.Equal(t, "https", info.scheme)
+	require.Equal(t, "www.example.com", info.host)
+}
+
+func TestResolveClientInfoAllTrusted(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://internal/", nil)
+	r.RemoteAddr = "10.0.0.2:4000"
+	r.Header.Set("X-Forwarded-For", "10.0.0.3, 10.0.0.1")
+
+	info := resolveClientInfo(r, parsePrefixes([]string{"10.0.0.0/8"}), ForwardedHeaderXForwardedFor)
+	require.Equal(t, "10.0.0.3", info.ip)
+}
+
+func TestResolveClientInfoSpoofedForwardedHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		xff    string
+		proto  string
+		host   string
+		scheme string
+		want   string
+	}{
+		{
+			name:   "appended by every hop",
+			xff:    "1.2.3.4, 198.51.100.1, 10.0.0.1",
+			proto:  "http, https, https",
+			host:   "evil.example.com, www.example.com, www.example.com",
+			scheme: "https",
+			want:   "www.example.com",
+		},
+		{
+			name:   "appended by the edge",
+			xff:    "198.51.100.1",
+			proto:  "http, https",
+			host:   "evil.example.com, www.example.com",
+			scheme: "https",
+			want:   "www.example.com",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://internal/", nil)
+			r.RemoteAddr = "10.0.0.2:4000"
+			r.Header.Set("X-Forwarded-For", test.xff)
+			r.Header.Set("X-Forwarded-Proto", test.proto)
+			r.Header.Set("X-Forwarded-Host", test.host)
+
+			info := resolveClientInfo(r, parsePrefixes([]string{"10.0.0.0/8"}), ForwardedHeaderXForwardedFor)
+			require.Equal(t, "198.51.100.1", info.ip)
+			require.Equal(t, test.scheme, info.scheme)
+			require.Equal(t, test.want, info.host)
+		})
+	}
+}
+
+func TestResolveClientInfoSpoofedForwarded(t *testing.T) {
+	// The proxy only appends X-Forwarded-For and passes the header of the client.
+	r := httptest.NewRequest("GET", "http://internal/", nil)
+	r.RemoteAddr = "10.0.0.2:4000"
+	r.Header.Set("Forwarded", "for=1.2.3.4;proto=https;host=evil.example.com")
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	info := resolveClientInfo(r, parsePrefixes([]string{"10.0.0.0/8"}), ForwardedHeaderXForwardedFor)
+	require.Equal(t, "198.51.100.1", info.ip)
+	require.Equal(t, "http", info.scheme)
+	require.Equal(t, "internal", info.host)
+
+	// And the other way around when the proxy writes the standard header.
+	r.Header.Set("Forwarded", "for=198.51.100.1")
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+	info = resolveClientInfo(r, parsePrefixes([]string{"10.0.0.0/8"}), ForwardedHeaderForwarded)
+	require.Equal(t, "198.51.100.1", info.ip)
+}
@@ -0,0 +1,319 @@
+package doris
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressOption configures the compression middleware.
+type CompressOption func(cnf *compressConfig)
+
+// WithCompressMinSize configures the minimum size of the responses to compress them.
+// Smaller responses are sent as is. By default it is 1024 bytes.
+func WithCompressMinSize(size int) CompressOption {
+	return func(cnf *compressConfig) {
+		cnf.minSize = size
+	}
+}
+
+// WithCompressContentTypes replaces the list of content types that will be compressed.
+// Types ending in a slash like "text/" match all the subtypes. By default it contains
+// the usual textual types of websites: HTML, CSS, JavaScript, JSON, XML, SVG, etc.
+func WithCompressContentTypes(types ...string) CompressOption {
+	return func(cnf *compressConfig) {
+		cnf.contentTypes = types
+	}
+}
+
+type compressConfig struct {
+	minSize      int
+	contentTypes []string
+}
+
+func (cnf *compressConfig) compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range cnf.contentTypes {
+		if mediaType == allowed || strings.HasSuffix(allowed, "/") && strings.HasPrefix(mediaType, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+type compressEncoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Encodings in order of preference when the client accepts several with the same weight.
+var compressEncodings = []string{"br", "zstd", "gzip"}
+
+var compressPools = map[string]*sync.Pool{
+	"br": {
+		New: func() any {
+			return brotli.NewWriterLevel(nil, 5)
+		},
+	},
+	"zstd": {
+		New: func() any {
+			enc, err := zstd.NewWriter(nil,
+				zstd.WithEncoderLevel(zstd.SpeedDefault),
+				zstd.WithEncoderConcurrency(1),
+				// Browsers do not accept windows bigger than 8 MB.
+				zstd.WithWindowSize(8<<20))
+			if err != nil {
+				panic(err)
+			}
+			return enc
+		},
+	},
+	"gzip": {
+		New: func() any {
+			return gzip.NewWriter(nil)
+		},
+	},
+}
+
+// Compress returns a middleware that compresses the responses negotiating the
+// encoding with the Accept-Encoding header of the client. It supports brotli,
+// zstd and gzip. Responses that already have a Content-Encoding are not modified.
+func Compress(opts ...CompressOption) func(http.Handler) http.Handler {
+	cnf := &compressConfig{
+		minSize: 1024,
+		contentTypes: []string{
+			"text/",
+			"application/javascript",
+			"application/json",
+			"application/manifest+json",
+			"application/xml",
+			"application/rss+xml",
+			"application/atom+xml",
+			"application/wasm",
+			"image/svg+xml",
+		},
+	}
+	for _, opt := range opts {
+		opt(cnf)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				cnf:            cnf,
+				encoding:       encoding,
+			}
+			defer cw.close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding selects the preferred encoding of the client among the supported ones.
+func negotiateEncoding(header string) string {
+	weights := parseAcceptEncoding(header)
+	var selected string
+	var best float64
+	for _, encoding := range compressEncodings {
+		if q := acceptedWeight(weights, encoding); q > best {
+			selected, best = encoding, q
+		}
+	}
+	return selected
+}
+
+// parseAcceptEncoding returns the weight of each encoding of the header.
+func parseAcceptEncoding(header string) map[string]float64 {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		weights[name] = q
+	}
+	return weights
+}
+
+func acceptedWeight(weights map[string]float64, encoding string) float64 {
+	if q, ok := weights[encoding]; ok {
+		return q
+	}
+	return weights["*"]
+}
+
+// compressWriter buffers the beginning of the response until it can decide if it
+// is worth compressing it.
+type compressWriter struct {
+	http.ResponseWriter
+	cnf      *compressConfig
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder compressEncoder
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	// Informational responses are sent directly and do not count as the final status.
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.status = status
+	if status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusSwitchingProtocols {
+		_ = w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.encoder != nil {
+			return w.encoder.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.cnf.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if err := w.decide(len(w.buf) >= w.cnf.minSize); err != nil {
+			return
+		}
+	}
+	if w.encoder != nil {
+		if err := w.encoder.Flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to access the original writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// decide sends the headers to the client compressing the response if it is big
+// enough and the rest of conditions are met.
+func (w *compressWriter) decide(bigEnough bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" && w.status != http.StatusSwitchingProtocols {
+		contentType := h.Get("Content-Type")
+		if contentType == "" && len(w.buf) > 0 {
+			contentType = http.DetectContentType(w.buf)
+			h.Set("Content-Type", contentType)
+		}
+		if w.cnf.compressible(contentType) {
+			if !varies(h, "Accept-Encoding") {
+				h.Add("Vary", "Accept-Encoding")
+			}
+			if bigEnough {
+				h.Del("Content-Length")
+				h.Set("Content-Encoding", w.encoding)
+				if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+					// The compressed representation is not byte-equal to the original.
+					h.Set("ETag", "W/"+etag)
+				}
+				w.encoder = compressPools[w.encoding].Get().(compressEncoder)
+				w.encoder.Reset(w.ResponseWriter)
+			}
+		}
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *compressWriter) close() {
+	if !w.decided {
+		// Handlers that do not write anything keep the default behaviour of net/http.
+		if w.status == 0 && len(w.buf) == 0 {
+			return
+		}
+		_ = w.decide(len(w.buf) >= w.cnf.minSize)
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+		w.encoder.Reset(nil)
+		compressPools[w.encoding].Put(w.encoder)
+		w.encoder = nil
+	}
+}
+
+// varies reports whether the Vary header of the response already lists the header.
+func varies(h http.Header, name string) bool {
+	for _, value := range h.Values("Vary") {
+		for _, item := range splitHeaderList(value) {
+			if item == "*" || strings.EqualFold(item, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
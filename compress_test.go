@@ -0,0 +1,121 @@
+package doris
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	require.Equal(t, "br", negotiateEncoding("gzip, deflate, br, zstd"))
+	require.Equal(t, "gzip", negotiateEncoding("gzip"))
+	require.Equal(t, "zstd", negotiateEncoding("br;q=0.5, zstd;q=0.8, gzip;q=0.1"))
+	require.Equal(t, "gzip", negotiateEncoding("br;q=0, gzip"))
+	require.Equal(t, "br", negotiateEncoding("*"))
+	require.Equal(t, "", negotiateEncoding("identity"))
+	require.Equal(t, "", negotiateEncoding(""))
+}
+
+func serveCompressed(handler http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", acceptEncoding)
+	w := httptest.NewRecorder()
+	Compress()(handler).ServeHTTP(w, r)
+	return w
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat("<p>hello world</p>", 200)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, body)
+	}
+
+	decoders := map[string]func(r io.Reader) io.Reader{
+		"gzip": func(r io.Reader) io.Reader {
+			gz, err := gzip.NewReader(r)
+			require.NoError(t, err)
+			return gz
+		},
+		"br": func(r io.Reader) io.Reader {
+			return brotli.NewReader(r)
+		},
+		"zstd": func(r io.Reader) io.Reader {
+			dec, err := zstd.NewReader(r)
+			require.NoError(t, err)
+			return dec
+		},
+	}
+	for encoding, decoder := range decoders {
+		t.Run(encoding, func(t *testing.T) {
+			// Run twice to reuse the encoders of the pool.
+			for range 2 {
+				w := serveCompressed(handler, encoding)
+				require.Equal(t, encoding, w.Header().Get("Content-Encoding"))
+				require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+
+				content, err := io.ReadAll(decoder(w.Body))
+				require.NoError(t, err)
+				require.Equal(t, body, string(content))
+			}
+		})
+	}
+}
+
+func TestCompressSmallResponse(t *testing.T) {
+	w := serveCompressed(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, "<p>hello</p>")
+	}, "gzip")
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	require.Equal(t, "<p>hello</p>", w.Body.String())
+}
+
+func TestCompressAlreadyEncoded(t *testing.T) {
+	w := serveCompressed(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Header().Set("Content-Encoding", "br")
+		_, _ = io.WriteString(w, strings.Repeat("x", 2048))
+	}, "gzip")
+	require.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	require.Equal(t, strings.Repeat("x", 2048), w.Body.String())
+}
+
+func TestCompressContentTypeNotAllowed(t *testing.T) {
+	w := serveCompressed(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = io.WriteString(w, strings.Repeat("x", 2048))
+	}, "gzip")
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Empty(t, w.Header().Get("Vary"))
+}
+
+func TestCompressExistingVary(t *testing.T) {
+	tests := []struct {
+		vary string
+		want []string
+	}{
+		{"Origin, Accept-Encoding", []string{"Origin, Accept-Encoding"}},
+		{"accept-encoding", []string{"accept-encoding"}},
+		{"Origin", []string{"Origin", "Accept-Encoding"}},
+		{"*", []string{"*"}},
+	}
+	for _, test := range tests {
+		t.Run(test.vary, func(t *testing.T) {
+			w := serveCompressed(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/html; charset=utf-8")
+				w.Header().Set("Vary", test.vary)
+				_, _ = io.WriteString(w, strings.Repeat("<p>hello world</p>", 200))
+			}, "gzip")
+			require.Equal(t, test.want, w.Header().Values("Vary"))
+		})
+	}
+}
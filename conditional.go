@@ -0,0 +1,264 @@
+package doris
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StrongETag returns a strong ETag with the hash of the content. It should be used
+// when the response is byte-equal every time it is generated.
+func StrongETag(content []byte) string {
+	h := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(h[:16]) + `"`
+}
+
+// WeakETag returns a weak ETag with the hash of the content. It should be used when
+// equivalent responses can change slightly, for example with a different formatting.
+func WeakETag(content []byte) string {
+	return "W/" + StrongETag(content)
+}
+
+// CheckPreconditions sets the ETag and Last-Modified headers of the response and
+// evaluates the conditional headers of the request against them. Any of them can be
+// empty if not known.
+//
+// It returns true if the response was already sent, with a 304 for GET and HEAD
+// requests whose content did not change or with a 412 error page for the rest of
+// failed preconditions. The handler should return without writing anything else.
+//
+//	if doris.CheckPreconditions(w, r, doris.StrongETag(content), post.UpdatedAt) {
+//	  return nil
+//	}
+func CheckPreconditions(w http.ResponseWriter, r *http.Request, etag string, lastModified time.Time) bool {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	switch evalPreconditions(r, w.Header()) {
+	case http.StatusNotModified:
+		writeNotModified(w)
+		return true
+	case http.StatusPreconditionFailed:
+		Error(w, http.StatusPreconditionFailed)
+		return true
+	}
+	return false
+}
+
+// evalPreconditions checks the conditional headers of the request with the ETag and
+// Last-Modified headers of the response in the order of RFC 9110. It returns the
+// status that should be sent instead of the response, or zero if it should be sent.
+func evalPreconditions(r *http.Request, h http.Header) int {
+	etag := h.Get("ETag")
+	lastModified, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		lastModified = time.Time{}
+	}
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !etagMatch(ifMatch, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if etagMatch(ifNoneMatch, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(since) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// etagMatch checks if the ETag is in the list of the header. Weak comparison
+// ignores the weak prefix of both sides.
+func etagMatch(header, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range splitHeaderList(header) {
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if candidate == etag && !strings.HasPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	delete(h, "Content-Type")
+	delete(h, "Content-Length")
+	delete(h, "Content-Encoding")
+	if h.Get("ETag") != "" {
+		delete(h, "Last-Modified")
+	}
+	w.WriteHeader(http.StatusNotModified)
+}
+
+// ConditionalOption configures the conditional requests middleware.
+type ConditionalOption func(cnf *conditionalConfig)
+
+// WithConditionalMaxSize configures the maximum size of the responses that will be
+// buffered to compute their ETag. Bigger responses are sent as is. By default it
+// is 1 MB.
+func WithConditionalMaxSize(size int) ConditionalOption {
+	return func(cnf *conditionalConfig) {
+		cnf.maxSize = size
+	}
+}
+
+type conditionalConfig struct {
+	maxSize int
+}
+
+// Conditional returns a middleware that buffers the successful responses of GET
+// and HEAD requests to compute a strong ETag if the handler did not set one, and
+// answers with a 304 when the content did not change for the client.
+func Conditional(opts ...ConditionalOption) func(http.Handler) http.Handler {
+	cnf := &conditionalConfig{
+		maxSize: 1 << 20,
+	}
+	for _, opt := range opts {
+		opt(cnf)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &conditionalWriter{
+				ResponseWriter: w,
+				cnf:            cnf,
+			}
+			next.ServeHTTP(cw, r)
+			cw.close(r)
+		})
+	}
+}
+
+// conditionalWriter buffers the response until it finishes or it is too big.
+type conditionalWriter struct {
+	http.ResponseWriter
+	cnf *conditionalConfig
+
+	status      int
+	buf         []byte
+	passthrough bool
+}
+
+func (w *conditionalWriter) WriteHeader(status int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status == 0 {
+		w.status = status
+	}
+	if w.status != http.StatusOK {
+		w.flush()
+	}
+}
+
+func (w *conditionalWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) > w.cnf.maxSize {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *conditionalWriter) Flush() {
+	if !w.passthrough {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		if err := w.flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap allows http.ResponseController to access the original writer.
+func (w *conditionalWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// flush stops buffering and sends what the handler wrote until now.
+func (w *conditionalWriter) flush() error {
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *conditionalWriter) close(r *http.Request) {
+	if w.passthrough {
+		return
+	}
+	// Handlers that do not write anything keep the default behaviour of net/http.
+	if w.status == 0 {
+		return
+	}
+
+	h := w.Header()
+	if h.Get("ETag") == "" && h.Get("Content-Encoding") == "" {
+		h.Set("ETag", StrongETag(w.buf))
+	}
+	if evalPreconditions(r, h) == http.StatusNotModified {
+		writeNotModified(w.ResponseWriter)
+		return
+	}
+	if h.Get("Content-Length") == "" && r.Method != http.MethodHead {
+		h.Set("Content-Length", strconv.Itoa(len(w.buf)))
+	}
+	_ = w.flush()
+}
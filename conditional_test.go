@@ -0,0 +1,78 @@
+package doris
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestETagMatch(t *testing.T) {
+	require.True(t, etagMatch(`"foo"`, `"foo"`, false))
+	require.True(t, etagMatch(`"bar", "foo"`, `"foo"`, false))
+	require.True(t, etagMatch(`*`, `"foo"`, false))
+	require.False(t, etagMatch(`W/"foo"`, `W/"foo"`, false))
+	require.True(t, etagMatch(`W/"foo"`, `"foo"`, true))
+	require.False(t, etagMatch(`"bar"`, `"foo"`, true))
+	require.False(t, etagMatch(`*`, "", true))
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	check := func(method string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/", nil)
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		if !CheckPreconditions(w, r, `"foo"`, modified) {
+			w.WriteHeader(http.StatusOK)
+		}
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, check(http.MethodGet).Code)
+	require.Equal(t, http.StatusNotModified, check(http.MethodGet, "If-None-Match", `"foo"`).Code)
+	require.Equal(t, http.StatusOK, check(http.MethodGet, "If-None-Match", `"bar"`).Code)
+	require.Equal(t, http.StatusNotModified, check(http.MethodGet, "If-Modified-Since", modified.Format(http.TimeFormat)).Code)
+	require.Equal(t, http.StatusOK, check(http.MethodGet, "If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat)).Code)
+	require.Equal(t, http.StatusOK, check(http.MethodPut, "If-Match", `"foo"`).Code)
+	require.Equal(t, http.StatusPreconditionFailed, check(http.MethodPut, "If-Match", `"bar"`).Code)
+	require.Equal(t, http.StatusPreconditionFailed, check(http.MethodPut, "If-None-Match", "*").Code)
+	require.Equal(t, http.StatusPreconditionFailed, check(http.MethodPut, "If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat)).Code)
+}
+
+func TestConditional(t *testing.T) {
+	handler := Conditional()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "hello")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "hello", w.Body.String())
+	require.Equal(t, StrongETag([]byte("hello")), w.Header().Get("ETag"))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Body.String())
+	require.Empty(t, w.Header().Get("Content-Type"))
+}
+
+func TestConditionalBigResponse(t *testing.T) {
+	handler := Conditional(WithConditionalMaxSize(3))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, "hello", w.Body.String())
+	require.Empty(t, w.Header().Get("ETag"))
+}
@@ -0,0 +1,201 @@
+package doris
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/altipla-consulting/env"
+)
+
+const (
+	// CSRFFieldName is the name of the form field that should contain the CSRF token.
+	CSRFFieldName = "csrf_token"
+
+	// CSRFHeader is the header that can contain the CSRF token in requests sent from JavaScript.
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// CSRFOption configures the CSRF protection middleware.
+type CSRFOption func(cnf *csrfConfig)
+
+// WithCSRFKey configures the secret key used to sign the tokens. All the instances
+// of the application should share the same key. By default a random one is generated
+// when the application starts.
+func WithCSRFKey(key []byte) CSRFOption {
+	return func(cnf *csrfConfig) {
+		cnf.key = key
+	}
+}
+
+// WithCSRFExempt adds path prefixes that won't be checked, for example webhooks
+// of external services. Connect APIs mounted with a hub are always exempted.
+func WithCSRFExempt(prefixes ...string) CSRFOption {
+	return func(cnf *csrfConfig) {
+		cnf.exempt = append(cnf.exempt, prefixes...)
+	}
+}
+
+// WithCSRFTrustedOrigins adds origins like "https://admin.example.com" that can send
+// requests to the application from a different site.
+func WithCSRFTrustedOrigins(origins ...string) CSRFOption {
+	return func(cnf *csrfConfig) {
+		cnf.trusted = append(cnf.trusted, origins...)
+	}
+}
+
+type csrfConfig struct {
+	key     []byte
+	exempt  []string
+	trusted []string
+}
+
+type csrfTokenKey struct{}
+
+// CSRFToken returns the token that templates should send in the CSRFFieldName field
+// of their forms. Responses that use it are not stored by Cache.
+// It returns an empty string if the CSRF middleware is not configured.
+func CSRFToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfTokenKey{}).(string)
+	if token != "" {
+		preventCache(ctx)
+	}
+	return token
+}
+
+// CSRF returns a middleware that protects forms against cross-site request forgery.
+//
+// Modern browsers are checked with the Sec-Fetch-Site and Origin headers they send.
+// Requests without those headers need a signed token in the CSRFFieldName form field
+// or the CSRFHeader header that matches the one of the cookie (double submit).
+// Rejected requests receive a 403 error page.
+func CSRF(opts ...CSRFOption) func(http.Handler) http.Handler {
+	cnf := new(csrfConfig)
+	for _, opt := range opts {
+		opt(cnf)
+	}
+	if len(cnf.key) == 0 {
+		cnf.key = make([]byte, 32)
+		_, _ = rand.Read(cnf.key)
+	}
+
+	cookieName := "__Host-csrf"
+	if env.IsLocal() {
+		cookieName = "csrf"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var token string
+			if cookie, err := r.Cookie(cookieName); err == nil && cnf.validToken(cookie.Value) {
+				token = cookie.Value
+			} else {
+				token = cnf.newToken()
+				http.SetCookie(w, &http.Cookie{
+					Name:     cookieName,
+					Value:    token,
+					Path:     "/",
+					Secure:   !env.IsLocal(),
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+			r = r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, token))
+
+			if !cnf.allowed(r, token) {
+				logger(r.Context()).Warn("CSRF check failed",
+					"url", r.URL.String(),
+					"origin", r.Header.Get("Origin"),
+					"sec-fetch-site", r.Header.Get("Sec-Fetch-Site"),
+					"client-ip", ClientIP(r.Context()))
+				Error(w, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (cnf *csrfConfig) allowed(r *http.Request, token string) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	if routeFromContext(r.Context()).connect {
+		return true
+	}
+	for _, prefix := range cnf.exempt {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+
+	origin := r.Header.Get("Origin")
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+		return true
+	case "":
+	default:
+		return cnf.trustedOrigin(origin)
+	}
+
+	if origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		host := RequestHost(r.Context())
+		if host == "" {
+			host = r.Host
+		}
+		return u.Host == host || cnf.trustedOrigin(origin)
+	}
+
+	// Old browsers and other clients without the headers need the token.
+	sent := r.Header.Get(CSRFHeader)
+	if sent == "" {
+		sent = r.PostFormValue(CSRFFieldName)
+	}
+	return subtle.ConstantTimeCompare([]byte(sent), []byte(token)) == 1
+}
+
+func (cnf *csrfConfig) trustedOrigin(origin string) bool {
+	for _, trusted := range cnf.trusted {
+		if strings.EqualFold(origin, trusted) {
+			return true
+		}
+	}
+	return false
+}
+
+// newToken generates a random value signed with the key of the middleware.
+func (cnf *csrfConfig) newToken() string {
+	value := make([]byte, 18)
+	_, _ = rand.Read(value)
+	return base64.RawURLEncoding.EncodeToString(value) + "." + cnf.sign(value)
+}
+
+func (cnf *csrfConfig) validToken(token string) bool {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(cnf.sign(value)))
+}
+
+func (cnf *csrfConfig) sign(value []byte) string {
+	mac := hmac.New(sha256.New, cnf.key)
+	mac.Write(value)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
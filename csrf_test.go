@@ -0,0 +1,75 @@
+package doris
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func serveCSRF(r *http.Request) (*httptest.ResponseRecorder, string) {
+	var token string
+	handler := CSRF(WithCSRFKey([]byte("test key")), WithCSRFExempt("/webhooks/"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = CSRFToken(r.Context())
+	}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w, token
+}
+
+func TestCSRFSafeMethod(t *testing.T) {
+	w, token := serveCSRF(httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NotEmpty(t, token)
+	require.Len(t, w.Result().Cookies(), 1)
+}
+
+func TestCSRFSecFetchSite(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Sec-Fetch-Site", "same-origin")
+	w, _ := serveCSRF(r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("Sec-Fetch-Site", "cross-site")
+	r.Header.Set("Origin", "https://evil.example.com")
+	w, _ = serveCSRF(r)
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCSRFOrigin(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "http://example.com/", nil)
+	r.Header.Set("Origin", "http://example.com")
+	w, _ := serveCSRF(r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	r = httptest.NewRequest(http.MethodPost, "http://example.com/", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+	w, _ = serveCSRF(r)
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCSRFToken(t *testing.T) {
+	w, token := serveCSRF(httptest.NewRequest(http.MethodGet, "/", nil))
+	cookie := w.Result().Cookies()[0]
+
+	form := url.Values{CSRFFieldName: {token}}
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(cookie)
+	w, _ = serveCSRF(r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	r.AddCookie(cookie)
+	w, _ = serveCSRF(r)
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCSRFExempt(t *testing.T) {
+	w, _ := serveCSRF(httptest.NewRequest(http.MethodPost, "/webhooks/stripe", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
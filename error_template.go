@@ -1,5 +1,27 @@
 package doris
 
+// errorTemplatePolicy is the Content-Security-Policy that allows the external resources
+// and the inline code of the error template.
+const errorTemplatePolicy = "default-src 'none'; " +
+	"script-src 'nonce-{nonce}' https://cdnjs.cloudflare.com; " +
+	"style-src 'nonce-{nonce}' https://cdnjs.cloudflare.com https://fonts.googleapis.com; " +
+	"font-src https://fonts.gstatic.com; " +
+	"img-src 'self' data:; " +
+	"base-uri 'none'; " +
+	"form-action 'none'; " +
+	"frame-ancestors 'none'"
+
+type errorTemplateData struct {
+	Status int
+	Nonce  string
+
+	// Maintenance explains the 503 errors of the maintenance mode.
+	Maintenance bool
+
+	// Overloaded explains the 503 errors of the load shedding.
+	Overloaded bool
+}
+
 const errorTemplate = `
 <!DOCTYPE html>
 <html lang="es">
@@ -9,19 +31,29 @@ const errorTemplate = `
   <meta name="viewport" content="width=device-width, initial-scale=1.0">
 
   <title>
-    Error {{.}} -
-    {{if eq . 400}}Petición erronea{{end}}
-    {{if eq . 401}}Falta autorización{{end}}
-    {{if eq . 403}}Falta permisos{{end}}
-    {{if eq . 404}}Página no encontrada{{end}}
-    {{if eq . 500}}Error interno del servidor{{end}}
-    {{if or (eq . 504) (eq . 408)}}Timeout interno del servidor{{end}}
+    Error {{.Status}} -
+    {{if eq .Status 400}}Petición erronea
+    {{else if eq .Status 401}}Falta autorización
+    {{else if eq .Status 403}}Falta permisos
+    {{else if eq .Status 404}}Página no encontrada
+    {{else if eq .Status 405}}Método no permitido
+    {{else if eq .Status 409}}Conflicto con el estado actual
+    {{else if eq .Status 410}}Página eliminada
+    {{else if eq .Status 412}}Condición previa fallida
+    {{else if eq .Status 413}}Petición demasiado grande
+    {{else if eq .Status 422}}Datos no válidos
+    {{else if eq .Status 429}}Demasiadas peticiones
+    {{else if eq .Status 500}}Error interno del servidor
+    {{else if eq .Status 503}}{{if .Maintenance}}Servicio en mantenimiento{{else}}Servicio no disponible{{end}}
+    {{else if or (eq .Status 504) (eq .Status 408)}}Timeout interno del servidor
+    {{else if lt .Status 500}}Petición no válida
+    {{else}}Error del servidor{{end}}
   </title>
 
-  <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/twitter-bootstrap/4.1.3/css/bootstrap.min.css">
-  <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/normalize/5.0.0/normalize.min.css">
+  <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/twitter-bootstrap/4.1.3/css/bootstrap.min.css" nonce="{{.Nonce}}">
+  <link rel="stylesheet" href="https://cdnjs.cloudflare.com/ajax/libs/normalize/5.0.0/normalize.min.css" nonce="{{.Nonce}}">
 
-  <style type="text/css">
+  <style type="text/css" nonce="{{.Nonce}}">
     @import url(https://fonts.googleapis.com/css?family=Nunito+Sans);:root{--blue:#0e0620;--white:#fff;--green:#2ccf6d}body,html{height:100%}body{display:flex;align-items:center;justify-content:center;font-family:"Nunito Sans";color:var(--blue);font-size:1.1em;padding-bottom:50px}button{font-family:"Nunito Sans"}ul{list-style-type:none;-webkit-padding-start:35px;padding-inline-start:35px}svg{width:100%;visibility:hidden}h1{font-size:7.5em;margin:15px 0;font-weight:700}h2{font-weight:700}.btn{z-index:1;overflow:hidden;background:0 0;position:relative;padding:8px 50px;border-radius:30px;cursor:pointer;font-size:1em;letter-spacing:2px;transition:.2s ease;font-weight:700;margin:5px 0}.btn.green{border:4px solid var(--green);color:var(--blue)}.btn.green:before{content:"";position:absolute;left:0;top:0;width:0%;height:100%;background:var(--green);z-index:-1;transition:.2s ease}.btn.green:hover{color:var(--white);background:var(--green);transition:.2s ease}.btn.green:hover:before{width:100%}@media screen and (max-width:768px){body{display:block}.container{margin-top:70px;margin-bottom:70px}}
   </style>
 
@@ -68,31 +100,72 @@ const errorTemplate = `
             278.436,375.599 383.003,264.076 364.393,251.618 264.807,364.928"stroke=#0E0620 stroke-miterlimit=10 stroke-width=3 /></g></g></g></g></svg>
         </div>
         <div class="col-md-6 align-self-center">
-          <h1>{{.}}</h1>
+          <h1>{{.Status}}</h1>
           <h2>
             Error:
-            {{if eq . 400}}Petición erronea{{end}}
-            {{if eq . 401}}Falta autorización{{end}}
-            {{if eq . 403}}Faltan permisos{{end}}
-            {{if eq . 404}}Página no encontrada{{end}}
-            {{if eq . 500}}Error interno del servidor{{end}}
-            {{if or (eq . 504) (eq . 408)}}Timeout interno del servidor{{end}}
+            {{if eq .Status 400}}Petición erronea
+            {{else if eq .Status 401}}Falta autorización
+            {{else if eq .Status 403}}Faltan permisos
+            {{else if eq .Status 404}}Página no encontrada
+            {{else if eq .Status 405}}Método no permitido
+            {{else if eq .Status 409}}Conflicto con el estado actual
+            {{else if eq .Status 410}}Página eliminada
+            {{else if eq .Status 412}}Condición previa fallida
+            {{else if eq .Status 413}}Petición demasiado grande
+            {{else if eq .Status 422}}Datos no válidos
+            {{else if eq .Status 429}}Demasiadas peticiones
+            {{else if eq .Status 500}}Error interno del servidor
+            {{else if eq .Status 503}}{{if .Maintenance}}Servicio en mantenimiento{{else}}Servicio no disponible{{end}}
+            {{else if or (eq .Status 504) (eq .Status 408)}}Timeout interno del servidor
+            {{else if lt .Status 500}}Petición no válida
+            {{else}}Error del servidor{{end}}
           </h2>
-          {{if eq . 400}}
+          {{if eq .Status 400}}
             <p>Su petición contiene información errónea que no podemos procesar en estos momentos.</p>
             <a href="/" class="btn green">Página principal</a>
-          {{end}}
-          {{if or (eq . 403) (eq . 401)}}
+          {{else if or (eq .Status 403) (eq .Status 401)}}
             <p>Necesita autenticarse con permisos adicionales para acceder a esta página. Contacte con nosotros para acceder.</p>
             <a href="/" class="btn green">Página principal</a>
-          {{end}}
-          {{if eq . 404}}
+          {{else if eq .Status 404}}
             <p>La página que busca no existe. Puede intentar volver a la página principal para encontrarla.</p>
             <a href="/" class="btn green">Página principal</a>
-          {{end}}
-          {{if or (eq . 500) (eq . 504) (eq . 408)}}
+          {{else if eq .Status 405}}
+            <p>La página no admite la acción que ha intentado realizar.</p>
+            <a href="/" class="btn green">Página principal</a>
+          {{else if eq .Status 409}}
+            <p>La información ha cambiado mientras realizaba la acción. Recargue la página y vuelva a intentarlo.</p>
+            <a href="" class="btn green">Recargar</a>
+          {{else if eq .Status 410}}
+            <p>La página que busca ya no existe. Puede volver a la página principal para encontrar otros contenidos.</p>
+            <a href="/" class="btn green">Página principal</a>
+          {{else if eq .Status 412}}
+            <p>El contenido ha cambiado desde que lo consultó. Recargue la página para ver la última versión.</p>
+            <a href="" class="btn green">Recargar</a>
+          {{else if eq .Status 413}}
+            <p>La información que ha enviado es demasiado grande para poder procesarla.</p>
+            <a href="/" class="btn green">Página principal</a>
+          {{else if eq .Status 422}}
+            <p>La información que ha enviado no es válida. Revísela y vuelva a intentarlo.</p>
+            <a href="/" class="btn green">Página principal</a>
+          {{else if eq .Status 429}}
+            <p>Ha realizado demasiadas peticiones en poco tiempo. Espere unos instantes antes de volver a intentarlo.</p>
+            <a href="" class="btn green mr-3">Recargar</a>
+            <a href="/" class="btn green">Página principal</a>
+          {{else if eq .Status 503}}
+            {{if .Maintenance}}
+              <p>Estamos realizando tareas de mantenimiento. Vuelva a intentarlo en unos minutos.</p>
+            {{else if .Overloaded}}
+              <p>El servicio está recibiendo demasiadas peticiones en estos momentos. Vuelva a intentarlo en unos instantes.</p>
+            {{else}}
+              <p>El servicio no está disponible temporalmente. Vuelva a intentarlo en unos instantes.</p>
+            {{end}}
+            <a href="" class="btn green">Recargar</a>
+          {{else if or (ge .Status 500) (eq .Status 408)}}
             <p>Pruebe a recargar en unos pocos segundos para ver si era un error temporal. En caso contrario hemos recibido notificación para arreglarlo lo antes posible.</p>
-            <a href="javascript: location.reload();" class="btn green mr-3">Recargar</a>
+            <a href="" class="btn green mr-3">Recargar</a>
+            <a href="/" class="btn green">Página principal</a>
+          {{else}}
+            <p>No hemos podido procesar su petición. Puede intentar volver a la página principal.</p>
             <a href="/" class="btn green">Página principal</a>
           {{end}}
         </div>
@@ -100,13 +173,13 @@ const errorTemplate = `
     </div>
   </main>
 
-  <script src="https://cdnjs.cloudflare.com/ajax/libs/gsap/3.1.1/gsap.min.js"></script>
+  <script src="https://cdnjs.cloudflare.com/ajax/libs/gsap/3.1.1/gsap.min.js" nonce="{{.Nonce}}"></script>
 
-  <script type="text/javascript">
+  <script type="text/javascript" nonce="{{.Nonce}}">
     gsap.set("svg",{visibility:"visible"}),gsap.to("#headStripe",{y:.5,rotation:1,yoyo:!0,repeat:-1,ease:"sine.inOut",duration:1}),gsap.to("#spaceman",{y:.5,rotation:1,yoyo:!0,repeat:-1,ease:"sine.inOut",duration:1}),gsap.to("#craterSmall",{x:-3,yoyo:!0,repeat:-1,duration:1,ease:"sine.inOut"}),gsap.to("#craterBig",{x:3,yoyo:!0,repeat:-1,duration:1,ease:"sine.inOut"}),gsap.to("#planet",{rotation:-2,yoyo:!0,repeat:-1,duration:1,ease:"sine.inOut",transformOrigin:"50% 50%"}),gsap.to("#starsBig g",{rotation:"random(-30,30)",transformOrigin:"50% 50%",yoyo:!0,repeat:-1,ease:"sine.inOut"}),gsap.fromTo("#starsSmall g",{scale:0,transformOrigin:"50% 50%"},{scale:1,transformOrigin:"50% 50%",yoyo:!0,repeat:-1,stagger:.1}),gsap.to("#circlesSmall circle",{y:-4,yoyo:!0,duration:1,ease:"sine.inOut",repeat:-1}),gsap.to("#circlesBig circle",{y:-2,yoyo:!0,duration:1,ease:"sine.inOut",repeat:-1}),gsap.set("#glassShine",{x:-68}),gsap.to("#glassShine",{x:80,duration:2,rotation:-30,ease:"expo.inOut",transformOrigin:"50% 50%",repeat:-1,repeatDelay:8,delay:2})
   </script>
 
-  <!-- Error {{.}} -->
+  <!-- Error {{.Status}} -->
 
 </body>
 </html>
@@ -1,6 +1,10 @@
 package doris
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
+
 	"connectrpc.com/connect"
 	"github.com/altipla-consulting/errors"
 )
@@ -8,3 +12,72 @@ import (
 func Errorf(code connect.Code, msg string, args ...any) error {
 	return errors.Trace(connect.NewError(code, errors.Errorf(msg, args...)))
 }
+
+// StatusError is an error returned from a HandlerError that renders the error page
+// of its status. Client errors are logged but not reported; server errors are
+// reported as any other error of the handler.
+type StatusError struct {
+	Status int
+	err    error
+}
+
+func (err *StatusError) Error() string {
+	return err.err.Error()
+}
+
+func (err *StatusError) Unwrap() error {
+	return err.err
+}
+
+// HTTPError returns an error that renders the error page of the status when returned
+// from a HandlerError, even if wrapped by other errors. Only 4xx and 5xx statuses
+// have an error page; the rest are replaced by a 500 error, as redirects or
+// successful responses need headers and content the error page cannot send.
+func HTTPError(status int, msg string, args ...any) error {
+	if status < http.StatusBadRequest || status > 599 {
+		return errors.Trace(&StatusError{
+			Status: http.StatusInternalServerError,
+			err:    errors.Errorf("invalid error status %d: %s", status, fmt.Sprintf(msg, args...)),
+		})
+	}
+	return errors.Trace(&StatusError{Status: status, err: errors.Errorf(msg, args...)})
+}
+
+// BadRequest returns an error that renders a 400 error page. See HTTPError.
+func BadRequest(msg string, args ...any) error {
+	return HTTPError(http.StatusBadRequest, msg, args...)
+}
+
+// Unauthorized returns an error that renders a 401 error page. See HTTPError.
+func Unauthorized(msg string, args ...any) error {
+	return HTTPError(http.StatusUnauthorized, msg, args...)
+}
+
+// Forbidden returns an error that renders a 403 error page. See HTTPError.
+func Forbidden(msg string, args ...any) error {
+	return HTTPError(http.StatusForbidden, msg, args...)
+}
+
+// NotFound returns an error that renders a 404 error page. See HTTPError.
+func NotFound(msg string, args ...any) error {
+	return HTTPError(http.StatusNotFound, msg, args...)
+}
+
+// rejectRequest answers the request with the error page of the status, or with the
+// equivalent Connect error if the route is an API mounted with a hub.
+func rejectRequest(w http.ResponseWriter, r *http.Request, status int, code connect.Code) {
+	rejectRequestPage(w, r, errorTemplateData{Status: status}, code)
+}
+
+// rejectRequestPage is like rejectRequest with custom data for the error page.
+func rejectRequestPage(w http.ResponseWriter, r *http.Request, data errorTemplateData, code connect.Code) {
+	if rw, ok := w.(*responseWriter); ok {
+		rw.rejected = true
+	}
+	if routeFromContext(r.Context()).connect {
+		err := connect.NewError(code, errors.New(strings.ToLower(http.StatusText(data.Status))))
+		_ = connect.NewErrorWriter().Write(w, r, err)
+		return
+	}
+	errorPage(w, data)
+}
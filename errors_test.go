@@ -0,0 +1,96 @@
+package doris
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerStatusErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"not found", NotFound("item %d not found", 3), http.StatusNotFound},
+		{"forbidden", Forbidden("not the owner"), http.StatusForbidden},
+		{"custom", HTTPError(http.StatusTooManyRequests, "slow down"), http.StatusTooManyRequests},
+		{"wrapped", fmt.Errorf("loading item: %w", NotFound("item not found")), http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := Handler(func(w http.ResponseWriter, r *http.Request) error {
+				return test.err
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			require.Equal(t, test.status, rec.Code)
+			require.Contains(t, rec.Body.String(), fmt.Sprintf("<h1>%d</h1>", test.status))
+		})
+	}
+}
+
+func TestHandlerServerStatusError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"server error", HTTPError(http.StatusServiceUnavailable, "backend down"), http.StatusServiceUnavailable},
+		{"redirect", HTTPError(http.StatusFound, "moved"), http.StatusInternalServerError},
+		{"success", HTTPError(http.StatusOK, "done"), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records := captureLogs(t)
+			handler := Handler(func(w http.ResponseWriter, r *http.Request) error {
+				return test.err
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			require.Equal(t, test.status, rec.Code)
+			require.Empty(t, rec.Header().Get("Location"))
+
+			// The handler logs the error and then reports it.
+			require.Len(t, *records, 2)
+			require.Equal(t, "Handler failed", (*records)[0].Message)
+			require.Equal(t, slog.LevelError, (*records)[1].Level)
+		})
+	}
+}
+
+func TestErrorPageStatuses(t *testing.T) {
+	tests := []struct {
+		status int
+		title  string
+	}{
+		{http.StatusMethodNotAllowed, "Método no permitido"},
+		{http.StatusConflict, "Conflicto con el estado actual"},
+		{http.StatusGone, "Página eliminada"},
+		{http.StatusUnprocessableEntity, "Datos no válidos"},
+		{http.StatusTeapot, "Petición no válida"},
+		{http.StatusBadGateway, "Error del servidor"},
+	}
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Error(rec, test.status)
+			require.Equal(t, test.status, rec.Code)
+			require.Contains(t, rec.Body.String(), test.title)
+			require.Contains(t, rec.Body.String(), `class="btn green"`)
+		})
+	}
+}
+
+func TestErrorPageUnavailable(t *testing.T) {
+	// A generic 503 does not blame the load of the server.
+	rec := httptest.NewRecorder()
+	Error(rec, http.StatusServiceUnavailable)
+	require.Contains(t, rec.Body.String(), "no está disponible temporalmente")
+	require.NotContains(t, rec.Body.String(), "demasiadas peticiones")
+	require.NotContains(t, rec.Body.String(), "mantenimiento")
+}
@@ -6,6 +6,7 @@ import (
 	"html/template"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/altipla-consulting/env"
 	"github.com/altipla-consulting/errors"
@@ -17,7 +18,8 @@ type HandlerError func(w http.ResponseWriter, r *http.Request) error
 
 func Handler(handler HandlerError) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		r = sentry.WithRequest(r)
+		r = withSentryRequest(r)
+		w = trackResponse(w)
 
 		defer func() {
 			if rec := errors.Recover(recover()); rec != nil {
@@ -32,10 +34,34 @@ func Handler(handler HandlerError) http.Handler {
 				return
 			}
 
-			slog.Error("Handler failed",
+			if maxErr := new(http.MaxBytesError); errors.As(err, &maxErr) {
+				logger(r.Context()).Warn("Request body too large",
+					slog.String("url", r.URL.String()),
+					slog.Int64("limit", maxErr.Limit),
+					slog.String("client-ip", ClientIP(r.Context())))
+				Error(w, http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			status := http.StatusInternalServerError
+			if statusErr := new(StatusError); errors.As(err, &statusErr) {
+				if statusErr.Status < http.StatusInternalServerError {
+					logger(r.Context()).Info("Handler rejected request",
+						slog.Int("status", statusErr.Status),
+						slog.String("error", err.Error()),
+						slog.String("url", r.URL.String()),
+						slog.String("client-ip", ClientIP(r.Context())))
+					Error(w, statusErr.Status)
+					return
+				}
+				status = statusErr.Status
+			}
+
+			logger(r.Context()).Error("Handler failed",
 				slog.String("error", err.Error()),
 				slog.String("details", errors.Details(err)),
-				slog.String("url", r.URL.String()))
+				slog.String("url", r.URL.String()),
+				slog.String("client-ip", ClientIP(r.Context())))
 			telemetry.ReportError(r.Context(), err)
 
 			if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
@@ -43,27 +69,66 @@ func Handler(handler HandlerError) http.Handler {
 				return
 			}
 
-			if env.IsLocal() {
-				w.WriteHeader(http.StatusInternalServerError)
+			if env.IsLocal() && !headersSent(w) {
+				w.WriteHeader(status)
 				fmt.Fprintln(w, errors.Stack(err))
 				return
 			}
 
-			Error(w, http.StatusInternalServerError)
+			Error(w, status)
 		}
 	})
 }
 
+// withSentryRequest prepares the Sentry scope of the request, reporting the real
+// client address instead of the one of the proxy.
+func withSentryRequest(r *http.Request) *http.Request {
+	report := r
+	if ip := ClientIP(r.Context()); ip != "" {
+		report = new(http.Request)
+		*report = *r
+		report.RemoteAddr = ip
+	}
+	ctx := sentry.WithRequest(report).Context()
+	if id := RequestID(ctx); id != "" {
+		sentry.Tag(ctx, "request_id", id)
+	}
+	return r.WithContext(ctx)
+}
+
+// Error renders the standard error page of the status. It does nothing if the
+// handler already sent the headers of the response, as the page would be mixed with
+// the content already sent.
 func Error(w http.ResponseWriter, status int) {
+	errorPage(w, errorTemplateData{Status: status})
+}
+
+func errorPage(w http.ResponseWriter, data errorTemplateData) {
+	if headersSent(w) {
+		return
+	}
+
+	// Replace the policy of the application if present with the one that allows the
+	// resources of the error page.
+	for _, header := range []string{"Content-Security-Policy", "Content-Security-Policy-Report-Only"} {
+		if w.Header().Get(header) != "" {
+			if data.Nonce == "" {
+				data.Nonce = generateNonce()
+			}
+			w.Header().Set(header, strings.ReplaceAll(errorTemplatePolicy, "{nonce}", data.Nonce))
+		}
+	}
+
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(status)
+	w.WriteHeader(data.Status)
 
 	tmpl, err := template.New("error").Parse(errorTemplate)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		slog.Error("Cannot parse error template", slog.String("error", err.Error()))
+		return
 	}
-	if err := tmpl.Execute(w, status); err != nil {
+	if err := tmpl.Execute(w, data); err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		slog.Error("Cannot execute error template", slog.String("error", err.Error()))
 	}
@@ -0,0 +1,35 @@
+package doris
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+)
+
+func metricsMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := trackResponse(w)
+		next.ServeHTTP(rw, r)
+
+		labels := fmt.Sprintf(`port=%q,route=%q,method=%q,status=%q`, sp.port, rt.pattern, metricsMethod(r.Method), statusClass(rw.Status()))
+		metrics.GetOrCreateCounter(`doris_http_requests_total{` + labels + `}`).Inc()
+		metrics.GetOrCreateHistogram(`doris_http_request_duration_seconds{` + labels + `}`).UpdateDuration(start)
+		metrics.GetOrCreateSummary(`doris_http_response_size_bytes{` + labels + `}`).Update(float64(rw.bytes))
+	})
+}
+
+// metricsMethod limits the cardinality of the method label to the standard ones.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+func statusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
@@ -0,0 +1,72 @@
+package doris
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusClass(t *testing.T) {
+	tests := []struct {
+		status int
+		class  string
+	}{
+		{http.StatusSwitchingProtocols, "1xx"},
+		{http.StatusOK, "2xx"},
+		{http.StatusNotModified, "3xx"},
+		{http.StatusNotFound, "4xx"},
+		{http.StatusServiceUnavailable, "5xx"},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.class, statusClass(test.status))
+	}
+}
+
+func TestMetricsMethod(t *testing.T) {
+	tests := []struct {
+		method string
+		label  string
+	}{
+		{http.MethodGet, "GET"},
+		{http.MethodPatch, "PATCH"},
+		{"PROPFIND", "OTHER"},
+		{"get", "OTHER"},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.label, metricsMethod(test.method))
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	sp := &ServerPort{port: "metrics-test"}
+	handler := metricsMiddleware(sp, &route{pattern: "/foo/{id}"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/foo/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "hello")
+	}))
+	for _, path := range []string{"/foo/1", "/foo/2", "/foo/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	var buf bytes.Buffer
+	metrics.WritePrometheus(&buf, false)
+	exported := buf.String()
+
+	ok := `port="metrics-test",route="/foo/{id}",method="GET",status="2xx"`
+	require.Contains(t, exported, `doris_http_requests_total{`+ok+`} 2`)
+	require.Contains(t, exported, `doris_http_request_duration_seconds_bucket{`+ok)
+	require.Contains(t, exported, `doris_http_response_size_bytes_sum{`+ok+`} 10`)
+	require.Contains(t, exported, `doris_http_response_size_bytes_count{`+ok+`} 2`)
+
+	notFound := `port="metrics-test",route="/foo/{id}",method="GET",status="4xx"`
+	require.Contains(t, exported, `doris_http_requests_total{`+notFound+`} 1`)
+	require.False(t, strings.Contains(exported, `port="metrics-test",route="/foo/1"`), "paths should not be used as labels")
+}
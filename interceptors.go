@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
@@ -19,9 +18,14 @@ import (
 )
 
 func ServerInterceptors() []connect.Interceptor {
+	return serverInterceptors(DefaultTimeout, nil)
+}
+
+func serverInterceptors(timeout time.Duration, procedureTimeouts map[string]time.Duration) []connect.Interceptor {
 	return []connect.Interceptor{
 		serverOnlyInterceptor(),
-		genericTimeoutInterceptor(),
+		tracingInterceptor(),
+		timeoutInterceptor(timeout, procedureTimeouts),
 		trimRequestsInterceptor(),
 		sentryLoggerInterceptor(),
 	}
@@ -38,16 +42,6 @@ func serverOnlyInterceptor() connect.Interceptor {
 	})
 }
 
-func genericTimeoutInterceptor() connect.Interceptor {
-	return connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
-		return connect.UnaryFunc(func(ctx context.Context, in connect.AnyRequest) (connect.AnyResponse, error) {
-			ctx, cancel := context.WithTimeout(ctx, 29*time.Second)
-			defer cancel()
-			return next(ctx, in)
-		})
-	})
-}
-
 func trimRequestsInterceptor() connect.Interceptor {
 	return connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
 		return connect.UnaryFunc(func(ctx context.Context, in connect.AnyRequest) (connect.AnyResponse, error) {
@@ -72,10 +66,16 @@ func sentryLoggerInterceptor() connect.Interceptor {
 				return nil, connect.NewError(connect.CodeInternal, err)
 			}
 			r.RemoteAddr = in.Peer().Addr
+			if ip := ClientIP(ctx); ip != "" {
+				r.RemoteAddr = ip
+			}
 			for k, v := range in.Header() {
 				r.Header[k] = v
 			}
 			ctx = sentry.WithRequest(r).Context()
+			if id := RequestID(ctx); id != "" {
+				sentry.Tag(ctx, "request_id", id)
+			}
 
 			reply, err := callProcedure(next, ctx, in)
 			if err != nil {
@@ -111,10 +111,11 @@ func callProcedure(next connect.UnaryFunc, ctx context.Context, in connect.AnyRe
 func logError(ctx context.Context, method string, err error) {
 	if connecterr := new(connect.Error); errors.As(err, &connecterr) {
 		// Always log the Connect errors.
-		slog.Error("Connect call failed",
+		logger(ctx).Error("Connect call failed",
 			"code", connecterr.Code().String(),
 			"message", connecterr.Message(),
 			"method", method,
+			"client-ip", ClientIP(ctx),
 		)
 
 		// Do not notify those status codes.
@@ -123,7 +124,11 @@ func logError(ctx context.Context, method string, err error) {
 			return
 		}
 	} else {
-		slog.Error("Unknown error in Connect call", "error", errors.LogValue(err))
+		logger(ctx).Error("Unknown error in Connect call",
+			"error", errors.LogValue(err),
+			"method", method,
+			"client-ip", ClientIP(ctx),
+		)
 	}
 
 	// Do not notify disconnections from the client.
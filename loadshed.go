@@ -0,0 +1,172 @@
+package doris
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// LoadShedOption configures the adaptive load shedding of a server port.
+type LoadShedOption func(limiter *concurrencyLimiter)
+
+// WithLoadShedLimits configures the bounds of the number of concurrent requests the
+// limiter can allow. By default they are 10 and 1000.
+func WithLoadShedLimits(minLimit, maxLimit int) LoadShedOption {
+	return func(limiter *concurrencyLimiter) {
+		limiter.minLimit = float64(minLimit)
+		limiter.maxLimit = float64(maxLimit)
+	}
+}
+
+// WithLoadShedTolerance configures how much the latency can grow over the normal one
+// before the limit is reduced. By default it is 1.5, allowing latencies up to 50%
+// higher than usual.
+func WithLoadShedTolerance(tolerance float64) LoadShedOption {
+	return func(limiter *concurrencyLimiter) {
+		limiter.tolerance = tolerance
+	}
+}
+
+// concurrencyLimiter adapts the number of concurrent requests using the gradient of
+// the latency: the limit grows while the latency stays close to the long term one
+// and shrinks as soon as it increases because the backends are saturated. Timeouts
+// and overloaded responses reduce it multiplicatively.
+type concurrencyLimiter struct {
+	minLimit  float64
+	maxLimit  float64
+	tolerance float64
+
+	inflight atomic.Int64
+
+	mu       sync.Mutex
+	limit    float64
+	shortRTT float64
+	longRTT  float64
+}
+
+func newConcurrencyLimiter() *concurrencyLimiter {
+	return &concurrencyLimiter{
+		minLimit:  10,
+		maxLimit:  1000,
+		tolerance: 1.5,
+	}
+}
+
+func (limiter *concurrencyLimiter) init() {
+	limiter.limit = math.Max(limiter.minLimit, math.Min(limiter.maxLimit, 50))
+}
+
+func (limiter *concurrencyLimiter) currentLimit() float64 {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	return limiter.limit
+}
+
+// acquire reserves a slot for a request. Critical requests are always accepted
+// although they count towards the limit of the rest.
+func (limiter *concurrencyLimiter) acquire(critical bool) bool {
+	inflight := limiter.inflight.Add(1)
+	if !critical && float64(inflight) > limiter.currentLimit() {
+		limiter.inflight.Add(-1)
+		return false
+	}
+	return true
+}
+
+// release frees the slot of the request and adapts the limit with its result.
+func (limiter *concurrencyLimiter) release(latency time.Duration, overloaded, sampled bool) {
+	inflight := limiter.inflight.Add(-1) + 1
+
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if overloaded {
+		limiter.limit = math.Max(limiter.minLimit, limiter.limit*0.9)
+		return
+	}
+	if !sampled {
+		return
+	}
+
+	rtt := latency.Seconds()
+	if limiter.longRTT == 0 {
+		limiter.shortRTT, limiter.longRTT = rtt, rtt
+		return
+	}
+	limiter.shortRTT = limiter.shortRTT*0.9 + rtt*0.1
+	limiter.longRTT = limiter.longRTT*0.99 + rtt*0.01
+
+	// Recover faster from a long period of high latency once it goes back to normal.
+	if limiter.longRTT/limiter.shortRTT > 2 {
+		limiter.longRTT *= 0.95
+	}
+
+	gradient := math.Max(0.5, math.Min(1, limiter.tolerance*limiter.longRTT/limiter.shortRTT))
+	target := limiter.limit*gradient + math.Sqrt(limiter.limit)
+	limit := limiter.limit*0.8 + target*0.2
+
+	// Do not grow the limit when the application is not using it.
+	if limit > limiter.limit && float64(inflight) < limiter.limit/2 {
+		return
+	}
+	limiter.limit = math.Max(limiter.minLimit, math.Min(limiter.maxLimit, limit))
+}
+
+// loadShedSampled excludes the streaming requests from the latency measurements.
+// The content type is only checked in the Connect APIs, where unary procedures
+// reject the streaming ones.
+func loadShedSampled(rt *route, r *http.Request) bool {
+	if rt.streaming {
+		return false
+	}
+	if !rt.connect {
+		return true
+	}
+	contentType := r.Header.Get("Content-Type")
+	return !strings.HasPrefix(contentType, "application/connect+") && !strings.HasPrefix(contentType, "application/grpc")
+}
+
+func loadShedMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	limiter := sp.loadShed
+	if limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !limiter.acquire(rt.critical != nil && rt.critical(r)) {
+			metrics.GetOrCreateCounter(fmt.Sprintf(`doris_http_load_shed_total{port=%q,route=%q}`, sp.port, rt.pattern)).Inc()
+			w.Header().Set("Retry-After", "1")
+			rejectRequestPage(w, r, errorTemplateData{Status: http.StatusServiceUnavailable, Overloaded: true}, connect.CodeUnavailable)
+			return
+		}
+
+		// Long lived connections are only admitted when there is capacity, they do not
+		// keep the slot while open or they would block the rest of the requests.
+		if rt.streaming {
+			limiter.inflight.Add(-1)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := trackResponse(w)
+		defer func() {
+			// Rejections like the maintenance mode do not say anything about the load.
+			if rw.rejected {
+				limiter.release(time.Since(start), false, false)
+				return
+			}
+			status := rw.Status()
+			overloaded := status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+			limiter.release(time.Since(start), overloaded, loadShedSampled(rt, r))
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,135 @@
+package doris
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimiterRejects(t *testing.T) {
+	limiter := newConcurrencyLimiter()
+	WithLoadShedLimits(2, 10)(limiter)
+	limiter.limit = 2
+
+	require.True(t, limiter.acquire(false))
+	require.True(t, limiter.acquire(false))
+	require.False(t, limiter.acquire(false))
+	require.True(t, limiter.acquire(true))
+}
+
+func TestConcurrencyLimiterAdapts(t *testing.T) {
+	limiter := newConcurrencyLimiter()
+	limiter.init()
+	initial := limiter.limit
+
+	// Saturate the limit with requests of a stable latency to let it grow.
+	limiter.inflight.Store(int64(initial))
+	for range 100 {
+		limiter.release(10*time.Millisecond, false, true)
+		limiter.inflight.Add(1)
+	}
+	grown := limiter.limit
+	require.Greater(t, grown, initial)
+
+	// Latency increases a lot because the backends are saturated.
+	for range 100 {
+		limiter.release(200*time.Millisecond, false, true)
+		limiter.inflight.Add(1)
+	}
+	require.Less(t, limiter.limit, grown)
+
+	reduced := limiter.limit
+	limiter.release(time.Second, true, true)
+	require.InDelta(t, reduced*0.9, limiter.limit, 0.001)
+}
+
+func TestLoadShedMiddleware(t *testing.T) {
+	sp := &ServerPort{loadShed: newConcurrencyLimiter()}
+	WithLoadShedLimits(1, 1)(sp.loadShed)
+	sp.loadShed.init()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := loadShedMiddleware(sp, new(route), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	go handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	<-started
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+	require.Contains(t, w.Body.String(), "Servicio no disponible")
+	require.Contains(t, w.Body.String(), "demasiadas peticiones")
+	require.NotContains(t, w.Body.String(), "mantenimiento")
+	close(release)
+}
+
+func TestLoadShedStreamingRoutes(t *testing.T) {
+	sp := &ServerPort{loadShed: newConcurrencyLimiter()}
+	WithLoadShedLimits(1, 1)(sp.loadShed)
+	sp.loadShed.init()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	streaming := loadShedMiddleware(sp, &route{streaming: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	go streaming.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws", nil))
+	<-started
+	defer close(release)
+
+	// The open connection does not keep the slot of the normal requests.
+	handler := loadShedMiddleware(sp, new(route), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLoadShedSampled(t *testing.T) {
+	eventStream := httptest.NewRequest(http.MethodGet, "/", nil)
+	eventStream.Header.Set("Accept", "text/event-stream")
+	require.True(t, loadShedSampled(new(route), eventStream), "headers of the client should not exclude the request")
+
+	require.False(t, loadShedSampled(&route{streaming: true}, httptest.NewRequest(http.MethodGet, "/", nil)))
+
+	stream := httptest.NewRequest(http.MethodPost, "/", nil)
+	stream.Header.Set("Content-Type", "application/connect+proto")
+	require.False(t, loadShedSampled(&route{connect: true}, stream))
+	require.True(t, loadShedSampled(new(route), stream))
+}
+
+func TestLoadShedMaintenance(t *testing.T) {
+	sp := &ServerPort{
+		loadShed: newConcurrencyLimiter(),
+		maintenance: &maintenanceConfig{
+			enabled: new(atomic.Bool),
+		},
+	}
+	sp.loadShed.init()
+	initial := sp.loadShed.limit
+	sp.maintenance.enabled.Store(true)
+
+	handler := loadShedMiddleware(sp, new(route), maintenanceMiddleware(sp, new(route), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})))
+	for range 10 {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	}
+	require.Equal(t, initial, sp.loadShed.limit)
+	require.Zero(t, sp.loadShed.inflight.Load())
+
+	// The errors of the handler reduce the limit.
+	sp.maintenance.enabled.Store(false)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Less(t, sp.loadShed.limit, initial)
+}
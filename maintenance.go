@@ -0,0 +1,80 @@
+package doris
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+type maintenanceConfig struct {
+	enabled    *atomic.Bool
+	allowed    []netip.Prefix
+	paths      []string
+	retryAfter time.Duration
+}
+
+func (cnf *maintenanceConfig) allowedRequest(r *http.Request) bool {
+	for _, prefix := range cnf.paths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	if addr, err := netip.ParseAddr(ClientIP(r.Context())); err == nil {
+		return containsAddr(cnf.allowed, addr)
+	}
+	return false
+}
+
+// SetMaintenance enables or disables the maintenance mode in all the ports of the
+// server. It can also be enabled when the application starts with the environment
+// variable MAINTENANCE_MODE=true, or at runtime sending a POST request to the
+// /maintenance/enable and /maintenance/disable endpoints of the internal port.
+func (server *Server) SetMaintenance(enabled bool) {
+	if server.maintenance.Swap(enabled) == enabled {
+		return
+	}
+	if enabled {
+		slog.Warn("Maintenance mode enabled")
+	} else {
+		slog.Info("Maintenance mode disabled")
+	}
+}
+
+// InMaintenance returns true if the maintenance mode is enabled.
+func (server *Server) InMaintenance() bool {
+	return server.maintenance.Load()
+}
+
+func (server *Server) maintenanceHandler(w http.ResponseWriter, r *http.Request) error {
+	fmt.Fprintf(w, "maintenance: %v\n", server.InMaintenance())
+	return nil
+}
+
+func (server *Server) enableMaintenanceHandler(w http.ResponseWriter, r *http.Request) error {
+	server.SetMaintenance(true)
+	return server.maintenanceHandler(w, r)
+}
+
+func (server *Server) disableMaintenanceHandler(w http.ResponseWriter, r *http.Request) error {
+	server.SetMaintenance(false)
+	return server.maintenanceHandler(w, r)
+}
+
+func maintenanceMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !sp.maintenance.enabled.Load() || sp.maintenance.allowedRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(sp.maintenance.retryAfter)))
+		rejectRequestPage(w, r, errorTemplateData{Status: http.StatusServiceUnavailable, Maintenance: true}, connect.CodeUnavailable)
+	})
+}
@@ -0,0 +1,70 @@
+package doris
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance(t *testing.T) {
+	sp := &ServerPort{
+		maintenance: &maintenanceConfig{
+			enabled:    new(atomic.Bool),
+			allowed:    parsePrefixes([]string{"10.0.0.0/8"}),
+			paths:      []string{"/status/"},
+			retryAfter: time.Minute,
+		},
+	}
+	handler := clientIPMiddleware(sp, new(route), maintenanceMiddleware(sp, new(route), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})))
+
+	serve := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, serve("/", "192.0.2.1:1234").Code)
+
+	sp.maintenance.enabled.Store(true)
+	w := serve("/", "192.0.2.1:1234")
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "60", w.Header().Get("Retry-After"))
+	require.Contains(t, w.Body.String(), "Servicio en mantenimiento")
+
+	require.Equal(t, http.StatusOK, serve("/", "10.1.2.3:1234").Code)
+	require.Equal(t, http.StatusOK, serve("/status/db", "192.0.2.1:1234").Code)
+
+	sp.maintenance.enabled.Store(false)
+	require.Equal(t, http.StatusOK, serve("/", "192.0.2.1:1234").Code)
+}
+
+func TestMaintenanceRoutes(t *testing.T) {
+	server := NewServer()
+	server.Get("/", func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+	server.Post("/submit", func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+	server.SetMaintenance(true)
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Contains(t, w.Body.String(), "Servicio en mantenimiento")
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/submit", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	server.SetMaintenance(false)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,112 @@
+package doris
+
+import (
+	"net/http"
+
+	"github.com/altipla-consulting/errors"
+	"github.com/altipla-consulting/telemetry"
+)
+
+// Builtin identifies one of the standard middlewares that doris applies to every
+// handler of the router. They run in the order of declaration of the constants
+// before any custom middleware. They can be disabled with WithoutBuiltin or replaced
+// with WithReplacedBuiltin.
+type Builtin string
+
+const (
+	// BuiltinClientIP resolves the real client behind trusted proxies. See ClientIP.
+	BuiltinClientIP Builtin = "client-ip"
+
+	// BuiltinRequestID receives or generates the ID of each request. See RequestID.
+	BuiltinRequestID Builtin = "request-id"
+
+	// BuiltinTracing starts an OpenTelemetry span for the request, continuing the trace
+	// of the W3C traceparent header if present. See WithTracing.
+	BuiltinTracing Builtin = "tracing"
+
+	// BuiltinAccessLog emits a log record for every request. See WithAccessLogSampling,
+	// WithSlowRequestThreshold and WithAccessLogExclusions to configure it.
+	BuiltinAccessLog Builtin = "access-log"
+
+	// BuiltinMetrics records the count, duration and response size of the requests
+	// in the metrics exported by the server.
+	BuiltinMetrics Builtin = "metrics"
+
+	// BuiltinLoadShed rejects the requests that exceed the adaptive concurrency limit.
+	// It is only enabled with WithLoadShedding.
+	BuiltinLoadShed Builtin = "load-shed"
+
+	// BuiltinTimeout cancels the context of the request after the configured timeout.
+	BuiltinTimeout Builtin = "timeout"
+
+	// BuiltinSentry prepares the request to be reported to Sentry.
+	BuiltinSentry Builtin = "sentry"
+
+	// BuiltinRecover recovers panics emitting an error page and reporting them.
+	BuiltinRecover Builtin = "recover"
+
+	// BuiltinMaintenance rejects the requests while the server is in maintenance mode.
+	// See Server.SetMaintenance.
+	BuiltinMaintenance Builtin = "maintenance"
+
+	// BuiltinBodyLimit rejects the requests with bodies bigger than the configured limit.
+	BuiltinBodyLimit Builtin = "body-limit"
+)
+
+type builtinMiddleware struct {
+	name Builtin
+	fn   func(sp *ServerPort, rt *route, next http.Handler) http.Handler
+}
+
+var builtins = []builtinMiddleware{
+	{BuiltinClientIP, clientIPMiddleware},
+	{BuiltinRequestID, requestIDMiddleware},
+	{BuiltinTracing, tracingMiddleware},
+	{BuiltinAccessLog, accessLogMiddleware},
+	{BuiltinMetrics, metricsMiddleware},
+	{BuiltinLoadShed, loadShedMiddleware},
+	{BuiltinTimeout, timeoutMiddleware},
+	{BuiltinSentry, sentryMiddleware},
+	{BuiltinRecover, recoverMiddleware},
+	{BuiltinMaintenance, maintenanceMiddleware},
+	{BuiltinBodyLimit, bodyLimitMiddleware},
+}
+
+func clientIPMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, withClientInfo(r, sp.trustedProxies, sp.forwardedHeader))
+	})
+}
+
+func timeoutMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	if rt.timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, cancel := withRequestTimeout(r, rt.timeout)
+		defer cancel()
+		defer logRequestTimeout(r, sp.port, rt)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func sentryMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, withSentryRequest(r))
+	})
+}
+
+func recoverMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w = trackResponse(w)
+		defer func() {
+			if rec := errors.Recover(recover()); rec != nil {
+				Error(w, http.StatusInternalServerError)
+				telemetry.ReportError(r.Context(), rec)
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,264 @@
+package doris
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+)
+
+var (
+	proxyV1Signature = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// Maximum length of a v1 header line including the final CRLF, as defined in the spec.
+const proxyV1MaxLength = 107
+
+type proxyProtocolConfig struct {
+	trusted []netip.Prefix
+	timeout time.Duration
+}
+
+func (cnf *proxyProtocolConfig) isTrusted(addr net.Addr) bool {
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return false
+	}
+	return containsAddr(cnf.trusted, ap.Addr())
+}
+
+// proxyListener reads the PROXY protocol header of the connections accepted from
+// trusted sources and exposes the real client address as the remote address of the
+// connection.
+type proxyListener struct {
+	net.Listener
+	cnf *proxyProtocolConfig
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.cnf.isTrusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &proxyConn{
+		Conn:    conn,
+		r:       bufio.NewReaderSize(conn, proxyV1MaxLength),
+		timeout: l.cnf.timeout,
+	}, nil
+}
+
+// proxyConn parses the header lazily the first time it is needed. That way
+// a slow client cannot block the accept loop of the whole server.
+type proxyConn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+
+	once   sync.Once
+	remote net.Addr
+	err    error
+
+	// deadline is the last read deadline set by the HTTP server, restored after
+	// reading the header with its own timeout.
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func (conn *proxyConn) init() {
+	conn.once.Do(func() {
+		if conn.timeout > 0 {
+			if err := conn.Conn.SetReadDeadline(time.Now().Add(conn.timeout)); err != nil {
+				conn.err = errors.Trace(err)
+				return
+			}
+			defer func() {
+				conn.mu.Lock()
+				defer conn.mu.Unlock()
+				if err := conn.Conn.SetReadDeadline(conn.deadline); err != nil && conn.err == nil {
+					conn.err = errors.Trace(err)
+				}
+			}()
+		}
+		conn.remote, conn.err = readProxyHeader(conn.r)
+		if conn.err != nil {
+			slog.Warn("Cannot read proxy protocol header",
+				slog.String("error", conn.err.Error()),
+				slog.String("remote-addr", conn.Conn.RemoteAddr().String()))
+		}
+	})
+}
+
+func (conn *proxyConn) SetDeadline(t time.Time) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.deadline = t
+	return conn.Conn.SetDeadline(t)
+}
+
+func (conn *proxyConn) SetReadDeadline(t time.Time) error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.deadline = t
+	return conn.Conn.SetReadDeadline(t)
+}
+
+func (conn *proxyConn) Read(b []byte) (int, error) {
+	conn.init()
+	if conn.err != nil {
+		return 0, conn.err
+	}
+	return conn.r.Read(b)
+}
+
+func (conn *proxyConn) RemoteAddr() net.Addr {
+	conn.init()
+	if conn.err != nil || conn.remote == nil {
+		return conn.Conn.RemoteAddr()
+	}
+	return conn.remote
+}
+
+// readProxyHeader consumes the PROXY protocol header. Trusted sources should always
+// send it, otherwise a misconfigured proxy would be served with its own address as the
+// client. It returns a nil address when the proxy sent the connection on its own
+// behalf, in which case the original address should be used.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.Trace(err)
+	}
+	switch {
+	case bytes.Equal(sig, proxyV2Signature):
+		return readProxyHeaderV2(r)
+	case bytes.HasPrefix(sig, proxyV1Signature):
+		return readProxyHeaderV1(r)
+	}
+	return nil, errors.Errorf("missing proxy protocol header")
+}
+
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return nil, errors.Errorf("proxy protocol v1 header too long")
+		}
+		return nil, errors.Trace(err)
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.Errorf("proxy protocol v1 header does not end with CRLF")
+	}
+
+	parts := strings.Split(string(line[:len(line)-2]), " ")
+	if len(parts) >= 2 && parts[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(parts) != 6 {
+		return nil, errors.Errorf("malformed proxy protocol v1 header: %q", line)
+	}
+	switch parts[1] {
+	case "TCP4", "TCP6":
+	default:
+		return nil, errors.Errorf("unknown proxy protocol v1 family: %q", parts[1])
+	}
+	ip, err := netip.ParseAddr(parts[2])
+	if err != nil {
+		return nil, errors.Errorf("malformed proxy protocol v1 source address: %w", err)
+	}
+	if ip.Is4() != (parts[1] == "TCP4") {
+		return nil, errors.Errorf("proxy protocol v1 source address %q does not match family %q", parts[2], parts[1])
+	}
+	port, err := strconv.ParseUint(parts[4], 10, 16)
+	if err != nil {
+		return nil, errors.Errorf("malformed proxy protocol v1 source port: %w", err)
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, uint16(port))), nil
+}
+
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if version := header[12] >> 4; version != 2 {
+		return nil, errors.Errorf("unsupported proxy protocol version: %d", version)
+	}
+	command := header[12] & 0x0f
+	family := header[13]
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	switch command {
+	case 0x0: // LOCAL: health checks of the proxy itself.
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, errors.Errorf("unknown proxy protocol v2 command: %d", command)
+	}
+
+	switch family {
+	case 0x11: // TCP over IPv4
+		if len(payload) < 12 {
+			return nil, errors.Errorf("proxy protocol v2 IPv4 addresses too short: %d bytes", len(payload))
+		}
+		ip := netip.AddrFrom4([4]byte(payload[0:4]))
+		port := binary.BigEndian.Uint16(payload[8:10])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), nil
+
+	case 0x21: // TCP over IPv6
+		if len(payload) < 36 {
+			return nil, errors.Errorf("proxy protocol v2 IPv6 addresses too short: %d bytes", len(payload))
+		}
+		ip := netip.AddrFrom16([16]byte(payload[0:16]))
+		port := binary.BigEndian.Uint16(payload[32:34])
+		return net.TCPAddrFromAddrPort(netip.AddrPortFrom(ip, port)), nil
+	}
+
+	// Other families (UDP, Unix sockets, unspecified) do not have a meaningful
+	// client address for HTTP. Keep the original one.
+	return nil, nil
+}
+
+func parsePrefixes(cidrs []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip, err := netip.ParseAddr(cidr)
+			if err != nil {
+				panic("doris: invalid IP address " + strconv.Quote(cidr) + ": " + err.Error())
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			panic("doris: invalid CIDR " + strconv.Quote(cidr) + ": " + err.Error())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes
+}
+
+func containsAddr(prefixes []netip.Prefix, ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+package doris
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadProxyHeaderV1(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PROXY TCP4 192.168.1.10 10.0.0.1 56324 443\r\nGET / HTTP/1.1\r\n"))
+	addr, err := readProxyHeader(r)
+	require.NoError(t, err)
+	require.Equal(t, "192.168.1.10:56324", addr.String())
+
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "GET / HTTP/1.1\r\n", string(rest))
+}
+
+func TestReadProxyHeaderV1Unknown(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PROXY UNKNOWN\r\nGET / HTTP/1.1\r\n"))
+	addr, err := readProxyHeader(r)
+	require.NoError(t, err)
+	require.Nil(t, addr)
+}
+
+func TestReadProxyHeaderV1Malformed(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PROXY TCP4 192.168.1.10 10.0.0.1\r\nGET / HTTP/1.1\r\n"))
+	_, err := readProxyHeader(r)
+	require.Error(t, err)
+}
+
+func TestReadProxyHeaderV2(t *testing.T) {
+	header := append([]byte(nil), proxyV2Signature...)
+	header = append(header, 0x21, 0x11)
+	header = binary.BigEndian.AppendUint16(header, 12)
+	header = append(header, 192, 168, 1, 10, 10, 0, 0, 1)
+	header = binary.BigEndian.AppendUint16(header, 56324)
+	header = binary.BigEndian.AppendUint16(header, 443)
+	header = append(header, "GET / HTTP/1.1\r\n"...)
+
+	r := bufio.NewReader(strings.NewReader(string(header)))
+	addr, err := readProxyHeader(r)
+	require.NoError(t, err)
+	require.Equal(t, "192.168.1.10:56324", addr.String())
+
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "GET / HTTP/1.1\r\n", string(rest))
+}
+
+func TestReadProxyHeaderV2Local(t *testing.T) {
+	header := append([]byte(nil), proxyV2Signature...)
+	header = append(header, 0x20, 0x00, 0x00, 0x00)
+
+	r := bufio.NewReader(strings.NewReader(string(header)))
+	addr, err := readProxyHeader(r)
+	require.NoError(t, err)
+	require.Nil(t, addr)
+}
+
+func TestReadProxyHeaderMissing(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n"))
+	_, err := readProxyHeader(r)
+	require.Error(t, err)
+}
+
+func newTestProxyListener(t *testing.T, timeout time.Duration, trusted ...string) *proxyListener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	return &proxyListener{
+		Listener: l,
+		cnf: &proxyProtocolConfig{
+			trusted: parsePrefixes(trusted),
+			timeout: timeout,
+		},
+	}
+}
+
+func TestProxyListenerTrusted(t *testing.T) {
+	l := newTestProxyListener(t, time.Second, "127.0.0.1/32")
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = io.WriteString(client, "PROXY TCP4 192.168.1.10 10.0.0.1 56324 443\r\nhello")
+	require.NoError(t, err)
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, "192.168.1.10:56324", conn.RemoteAddr().String())
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(buf))
+}
+
+func TestProxyListenerUntrusted(t *testing.T) {
+	l := newTestProxyListener(t, time.Second, "10.0.0.0/8")
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	header := "PROXY TCP4 192.168.1.10 10.0.0.1 56324 443\r\n"
+	_, err = io.WriteString(client, header)
+	require.NoError(t, err)
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, client.LocalAddr().String(), conn.RemoteAddr().String())
+
+	// The header of untrusted sources reaches the application untouched.
+	buf := make([]byte, len(header))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	require.Equal(t, header, string(buf))
+}
+
+func TestProxyListenerTimeout(t *testing.T) {
+	l := newTestProxyListener(t, 50*time.Millisecond, "127.0.0.1/32")
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	start := time.Now()
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	require.True(t, netErr.Timeout())
+	require.Less(t, time.Since(start), time.Second)
+	require.Equal(t, client.LocalAddr().String(), conn.RemoteAddr().String())
+}
+
+func TestProxyListenerMissingHeader(t *testing.T) {
+	l := newTestProxyListener(t, time.Second, "127.0.0.1/32")
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = io.WriteString(client, "GET / HTTP/1.1\r\n")
+	require.NoError(t, err)
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Read(make([]byte, 1))
+	require.ErrorContains(t, err, "missing proxy protocol header")
+}
+
+func TestProxyListenerRestoresDeadline(t *testing.T) {
+	l := newTestProxyListener(t, time.Second, "127.0.0.1/32")
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = io.WriteString(client, "PROXY TCP4 192.168.1.10 10.0.0.1 56324 443\r\n")
+	require.NoError(t, err)
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The deadline of the server is kept after reading the header with its own timeout.
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	start := time.Now()
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	require.True(t, netErr.Timeout())
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
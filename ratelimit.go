@@ -0,0 +1,271 @@
+package doris
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/altipla-consulting/errors"
+)
+
+// RateLimitPolicy describes a token bucket that allows Limit requests every Period,
+// with bursts of up to Burst requests.
+type RateLimitPolicy struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// RateLimitResult is the state of a bucket after taking a token from it.
+type RateLimitResult struct {
+	// Allowed is true if there was a token available for the request.
+	Allowed bool
+
+	// Remaining is the number of tokens left in the bucket.
+	Remaining int
+
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+
+	// RetryAfter is the time until the next token is available if the request was
+	// not allowed.
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the state of the buckets. The default implementation keeps
+// them in memory; applications with several instances can implement it on top of
+// a shared backend like Redis.
+type RateLimitStore interface {
+	// Take consumes a token of the bucket identified by key.
+	Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+// RateLimitOption configures the rate limiting middleware.
+type RateLimitOption func(cnf *rateLimitConfig)
+
+// WithRateLimitBurst configures the maximum number of requests that can be sent at
+// once. By default it is the same as the limit.
+func WithRateLimitBurst(burst int) RateLimitOption {
+	return func(cnf *rateLimitConfig) {
+		cnf.policy.Burst = burst
+	}
+}
+
+// WithRateLimitHeaderKey limits the requests by the value of a header, for example
+// an API key. Requests without the header are limited by the client IP.
+//
+// The values are not validated: any client can send a different one in each request
+// to get a new bucket, growing the memory of the store. Use it only with headers
+// checked by a previous middleware, or use WithRateLimitKeyFunc to limit by the
+// authenticated identity instead.
+func WithRateLimitHeaderKey(header string) RateLimitOption {
+	return func(cnf *rateLimitConfig) {
+		cnf.key = func(r *http.Request) string {
+			if value := r.Header.Get(header); value != "" {
+				return header + ":" + value
+			}
+			return rateLimitClientKey(r)
+		}
+	}
+}
+
+// WithRateLimitKeyFunc limits the requests by a custom key, for example the ID
+// of the authenticated user. Requests with an empty key are not limited.
+func WithRateLimitKeyFunc(fn func(r *http.Request) string) RateLimitOption {
+	return func(cnf *rateLimitConfig) {
+		cnf.key = fn
+	}
+}
+
+// WithRateLimitPerRoute keeps a different bucket for each route, so the clients can
+// send limit requests to every route instead of limit requests in total. It is useful
+// when the middleware is added to the whole router with Use.
+func WithRateLimitPerRoute() RateLimitOption {
+	return func(cnf *rateLimitConfig) {
+		cnf.perRoute = true
+	}
+}
+
+// WithRateLimitStore changes the store of the buckets. By default they are kept
+// in the memory of each instance.
+func WithRateLimitStore(store RateLimitStore) RateLimitOption {
+	return func(cnf *rateLimitConfig) {
+		cnf.store = store
+	}
+}
+
+type rateLimitConfig struct {
+	policy   RateLimitPolicy
+	key      func(r *http.Request) string
+	store    RateLimitStore
+	perRoute bool
+}
+
+// RateLimit returns a middleware that allows limit requests every period to each
+// client, adding up the requests to all the routes where it is applied. By default
+// clients are identified by their IP.
+//
+// Use it with WithRouteMiddlewares to apply different limits to each prefix, or with
+// WithRateLimitPerRoute to limit each route separately. Middlewares that share a
+// store with WithRateLimitStore share the buckets of the clients too. Excess requests
+// receive the standard RateLimit-* and Retry-After headers with a 429 error page or a
+// ResourceExhausted error in Connect APIs.
+func RateLimit(limit int, period time.Duration, opts ...RateLimitOption) func(http.Handler) http.Handler {
+	cnf := &rateLimitConfig{
+		policy: RateLimitPolicy{
+			Limit:  limit,
+			Period: period,
+			Burst:  limit,
+		},
+		key: rateLimitClientKey,
+	}
+	for _, opt := range opts {
+		opt(cnf)
+	}
+	if err := cnf.policy.validate(); err != nil {
+		panic(err)
+	}
+	if cnf.store == nil {
+		cnf.store = NewMemoryRateLimitStore()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := cnf.key(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			pattern := routeFromContext(r.Context()).pattern
+			if cnf.perRoute {
+				key = pattern + "|" + key
+			}
+
+			result, err := cnf.store.Take(r.Context(), key, cnf.policy)
+			if err != nil {
+				// Fail open, a broken store should not take down the application.
+				logger(r.Context()).Error("Cannot check rate limit",
+					"error", err.Error(),
+					"url", r.URL.String())
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(cnf.policy.Burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+			if !result.Allowed {
+				metrics.GetOrCreateCounter(fmt.Sprintf(`doris_http_rate_limited_total{route=%q}`, pattern)).Inc()
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				rejectRequest(w, r, http.StatusTooManyRequests, connect.CodeResourceExhausted)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitClientKey identifies the client by its IP. It uses the address of the
+// connection if the client-ip builtin did not run, as a shared empty key would
+// lock out every client at once.
+func rateLimitClientKey(r *http.Request) string {
+	if ip := ClientIP(r.Context()); ip != "" {
+		return "ip:" + ip
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return "ip:" + host
+	}
+	return "ip:" + r.RemoteAddr
+}
+
+func (policy RateLimitPolicy) validate() error {
+	if policy.Limit <= 0 || policy.Period <= 0 || policy.Burst <= 0 {
+		return errors.Errorf("invalid rate limit policy: limit %d, period %s and burst %d should be positive", policy.Limit, policy.Period, policy.Burst)
+	}
+	return nil
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore keeps the buckets in the memory of the instance.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// NewMemoryRateLimitStore creates a new empty store in memory.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take implements RateLimitStore.
+func (store *MemoryRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	if err := policy.validate(); err != nil {
+		return RateLimitResult{}, errors.Trace(err)
+	}
+
+	now := time.Now()
+	rate := float64(policy.Limit) / policy.Period.Seconds()
+	burst := float64(policy.Burst)
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.sweep(now)
+
+	bucket, ok := store.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: burst, last: now}
+		store.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	var result RateLimitResult
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = time.Duration((burst - bucket.tokens) / rate * float64(time.Second))
+	bucket.full = now.Add(result.Reset)
+
+	return result, nil
+}
+
+// sweep removes the buckets that are full again from time to time to keep the
+// memory bounded.
+func (store *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < time.Minute {
+		return
+	}
+	store.lastSweep = now
+	for key, bucket := range store.buckets {
+		if now.After(bucket.full) {
+			delete(store.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package doris
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	store := NewMemoryRateLimitStore()
+	policy := RateLimitPolicy{Limit: 2, Period: time.Hour, Burst: 2}
+
+	result, err := store.Take(context.Background(), "foo", policy)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 1, result.Remaining)
+
+	result, err = store.Take(context.Background(), "foo", policy)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+
+	result, err = store.Take(context.Background(), "foo", policy)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.InDelta(t, 30*time.Minute, result.RetryAfter, float64(time.Second))
+
+	result, err = store.Take(context.Background(), "bar", policy)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+}
+
+func TestRateLimit(t *testing.T) {
+	handler := RateLimit(1, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "60", w.Header().Get("Retry-After"))
+	require.Contains(t, w.Body.String(), "Demasiadas peticiones")
+}
+
+func TestRateLimitWithoutClientIP(t *testing.T) {
+	// Without the client-ip builtin each connection address gets its own bucket.
+	handler := RateLimit(1, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		remote string
+		status int
+	}{
+		{"192.0.2.1:1234", http.StatusOK},
+		{"192.0.2.1:5678", http.StatusTooManyRequests},
+		{"192.0.2.2:1234", http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = test.remote
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		require.Equal(t, test.status, w.Code, test.remote)
+	}
+}
+
+func TestRateLimitInvalidPolicy(t *testing.T) {
+	require.Panics(t, func() { RateLimit(0, time.Minute) })
+	require.Panics(t, func() { RateLimit(10, 0) })
+	require.Panics(t, func() { RateLimit(10, time.Minute, WithRateLimitBurst(0)) })
+
+	_, err := NewMemoryRateLimitStore().Take(context.Background(), "foo", RateLimitPolicy{Limit: 1, Burst: 1})
+	require.Error(t, err)
+}
+
+func TestRateLimitRoutes(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []RateLimitOption
+		status int
+	}{
+		{"total", nil, http.StatusTooManyRequests},
+		{"per route", []RateLimitOption{WithRateLimitPerRoute()}, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := NewServer()
+			server.Use(RateLimit(1, time.Minute, test.opts...))
+			server.Get("/foo", func(w http.ResponseWriter, r *http.Request) error { return nil })
+			server.Get("/bar", func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo", nil))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			w = httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bar", nil))
+			require.Equal(t, test.status, w.Code)
+		})
+	}
+}
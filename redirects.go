@@ -0,0 +1,156 @@
+package doris
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/altipla-consulting/env"
+)
+
+// TrailingSlash is the policy to normalize the trailing slash of the paths.
+type TrailingSlash int
+
+const (
+	// TrailingSlashIgnore does not modify the paths.
+	TrailingSlashIgnore TrailingSlash = iota
+
+	// TrailingSlashAdd redirects the paths without a trailing slash to the ones with it.
+	// Paths that look like files with an extension are not modified.
+	TrailingSlashAdd
+
+	// TrailingSlashRemove redirects the paths with a trailing slash to the ones without it.
+	TrailingSlashRemove
+)
+
+// RedirectOption configures the redirects middleware.
+type RedirectOption func(cnf *redirectConfig)
+
+// WithCanonicalHost redirects any other host to this one, for example to move
+// "www.example.com" to "example.com".
+func WithCanonicalHost(host string) RedirectOption {
+	return func(cnf *redirectConfig) {
+		cnf.host = strings.ToLower(host)
+	}
+}
+
+// WithHTTPSRedirect redirects the requests sent with HTTP to HTTPS. It takes into
+// account the scheme forwarded by trusted proxies. See RequestScheme. It has no
+// effect when running locally.
+func WithHTTPSRedirect() RedirectOption {
+	return func(cnf *redirectConfig) {
+		cnf.https = true
+	}
+}
+
+// WithTrailingSlash configures the policy for the trailing slash of the paths.
+func WithTrailingSlash(policy TrailingSlash) RedirectOption {
+	return func(cnf *redirectConfig) {
+		cnf.trailingSlash = policy
+	}
+}
+
+// WithLowercasePaths redirects the paths with uppercase letters to the lowercase version.
+// Files served with Static and paths with an extension are not modified, as the names
+// of the files are case sensitive.
+func WithLowercasePaths() RedirectOption {
+	return func(cnf *redirectConfig) {
+		cnf.lowercase = true
+	}
+}
+
+type redirectConfig struct {
+	host          string
+	https         bool
+	trailingSlash TrailingSlash
+	lowercase     bool
+}
+
+// Redirects returns a middleware that sends all the requests to the canonical URL
+// of the site in a single redirect. Safe methods receive a 301 and the rest a 308 to
+// preserve the method and body. The query string is kept and Connect APIs mounted
+// with a hub are never redirected.
+func Redirects(opts ...RedirectOption) func(http.Handler) http.Handler {
+	cnf := new(redirectConfig)
+	for _, opt := range opts {
+		opt(cnf)
+	}
+	if env.IsLocal() {
+		cnf.https = false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if routeFromContext(r.Context()).connect {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			target, ok := cnf.canonical(r, routeFromContext(r.Context()))
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			status := http.StatusPermanentRedirect
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				status = http.StatusMovedPermanently
+			}
+			http.Redirect(w, r, target, status)
+		})
+	}
+}
+
+// canonical returns the canonical URL of the request and true if it is different
+// from the current one.
+func (cnf *redirectConfig) canonical(r *http.Request, rt *route) (string, bool) {
+	scheme := RequestScheme(r.Context())
+	if scheme == "" {
+		scheme = "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+	}
+	host := RequestHost(r.Context())
+	if host == "" {
+		host = r.Host
+	}
+	// Work over the escaped path to keep the encoded characters like %2F.
+	p := r.URL.EscapedPath()
+
+	var changed bool
+	if cnf.https && scheme != "https" {
+		scheme, changed = "https", true
+	}
+	if cnf.host != "" && !strings.EqualFold(host, cnf.host) {
+		host, changed = cnf.host, true
+	}
+	if cnf.lowercase && !rt.static && path.Ext(p) == "" && strings.ToLower(p) != p {
+		p, changed = strings.ToLower(p), true
+	}
+	switch cnf.trailingSlash {
+	case TrailingSlashAdd:
+		if !strings.HasSuffix(p, "/") && path.Ext(p) == "" {
+			p, changed = p+"/", true
+		}
+	case TrailingSlashRemove:
+		if trimmed := strings.TrimRight(p, "/"); trimmed != p && trimmed != "" {
+			p, changed = trimmed, true
+		}
+	}
+	if !changed {
+		return "", false
+	}
+
+	unescaped, err := url.PathUnescape(p)
+	if err != nil {
+		return "", false
+	}
+	u := *r.URL
+	u.Scheme = scheme
+	u.Host = host
+	u.Path = unescaped
+	u.RawPath = p
+	return u.String(), true
+}
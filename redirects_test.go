@@ -0,0 +1,73 @@
+package doris
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func serveRedirects(method, target string, opts ...RedirectOption) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	Redirects(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestRedirectsCanonicalHost(t *testing.T) {
+	w := serveRedirects(http.MethodGet, "http://www.example.com/foo?bar=baz", WithCanonicalHost("example.com"))
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+	require.Equal(t, "http://example.com/foo?bar=baz", w.Header().Get("Location"))
+
+	w = serveRedirects(http.MethodGet, "http://example.com/foo", WithCanonicalHost("example.com"))
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRedirectsPreserveMethod(t *testing.T) {
+	w := serveRedirects(http.MethodPost, "http://www.example.com/foo", WithCanonicalHost("example.com"))
+	require.Equal(t, http.StatusPermanentRedirect, w.Code)
+}
+
+func TestRedirectsPaths(t *testing.T) {
+	w := serveRedirects(http.MethodGet, "http://example.com/Foo/Bar", WithLowercasePaths(), WithTrailingSlash(TrailingSlashAdd))
+	require.Equal(t, "http://example.com/foo/bar/", w.Header().Get("Location"))
+
+	w = serveRedirects(http.MethodGet, "http://example.com/app.js", WithTrailingSlash(TrailingSlashAdd))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serveRedirects(http.MethodGet, "http://example.com/foo/", WithTrailingSlash(TrailingSlashRemove))
+	require.Equal(t, "http://example.com/foo", w.Header().Get("Location"))
+
+	w = serveRedirects(http.MethodGet, "http://example.com/", WithTrailingSlash(TrailingSlashRemove))
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRedirectsConnect(t *testing.T) {
+	handler := Redirects(WithCanonicalHost("example.com"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodPost, "http://www.example.com/foo.v1.FooService/Bar", nil)
+	r = r.WithContext(context.WithValue(r.Context(), routeKey{}, &route{connect: true}))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRedirectsLowercaseFiles(t *testing.T) {
+	w := serveRedirects(http.MethodGet, "http://example.com/assets/App.3F2A1B9C.js", WithLowercasePaths())
+	require.Equal(t, http.StatusOK, w.Code)
+
+	handler := Redirects(WithLowercasePaths())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/static/Docs", nil)
+	r = r.WithContext(context.WithValue(r.Context(), routeKey{}, &route{static: true}))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRedirectsEscapedPath(t *testing.T) {
+	w := serveRedirects(http.MethodGet, "http://example.com/Files/a%2Fb", WithLowercasePaths())
+	require.Equal(t, "http://example.com/files/a%2fb", w.Header().Get("Location"))
+
+	w = serveRedirects(http.MethodGet, "http://example.com/files/a%2Fb", WithTrailingSlash(TrailingSlashAdd))
+	require.Equal(t, "http://example.com/files/a%2Fb/", w.Header().Get("Location"))
+}
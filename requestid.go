@@ -0,0 +1,93 @@
+package doris
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"connectrpc.com/connect"
+)
+
+// RequestIDHeader is the header used to receive, echo and propagate the ID of each request.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// RequestID returns the ID of the request being served. It is received from the
+// client or the load balancer if present or generated by doris otherwise.
+// It returns an empty string outside a request handled by doris.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID returns a new context that will propagate the request ID to the
+// outgoing calls made with RequestIDInterceptor.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func requestIDMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(id) {
+			id = generateRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// isValidRequestID accepts IDs coming from clients only if they are reasonably
+// sized and do not contain characters that may break logs or headers.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '/', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func generateRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// logger returns the logger that should be used to emit records related to the
+// request of the context.
+func logger(ctx context.Context) *slog.Logger {
+	attrs := traceAttrs(ctx)
+	if id := RequestID(ctx); id != "" {
+		attrs = append(attrs, "request-id", id)
+	}
+	if len(attrs) == 0 {
+		return slog.Default()
+	}
+	return slog.Default().With(attrs...)
+}
+
+// RequestIDInterceptor propagates the ID of the request being served to the outgoing
+// calls of a Connect client.
+func RequestIDInterceptor() connect.Interceptor {
+	return connect.UnaryInterceptorFunc(
+		func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+				if id := RequestID(ctx); id != "" && req.Header().Get(RequestIDHeader) == "" {
+					req.Header().Set(RequestIDHeader, id)
+				}
+				return next(ctx, req)
+			}
+		},
+	)
+}
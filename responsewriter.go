@@ -0,0 +1,124 @@
+package doris
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter records the status and size of the response sent to the client,
+// and whether the headers were already sent so it is too late to render an error page.
+type responseWriter struct {
+	http.ResponseWriter
+
+	status int
+	bytes  int64
+
+	// rejected is set when a middleware of doris answered the request before the
+	// handler, like the maintenance mode does.
+	rejected bool
+}
+
+// trackResponse wraps the writer to record the response. It reuses the wrapper if
+// another middleware already applied it.
+func trackResponse(w http.ResponseWriter) *responseWriter {
+	if rw, ok := w.(*responseWriter); ok {
+		return rw
+	}
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	// Informational responses are sent before the final one.
+	if w.status == 0 && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ReadFrom keeps the optimizations of the original writer to send files.
+func (w *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, src)
+	}
+	w.bytes += n
+	return n, err
+}
+
+// writerOnly hides the ReadFrom method of the writer to avoid infinite recursion.
+type writerOnly struct {
+	io.Writer
+}
+
+// Hijack allows handlers like websockets to take over the connection.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := hj.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap allows http.ResponseController to access the original writer.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the status sent to the client, or 200 if the handler did not
+// write anything as net/http will do.
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// HeadersSent returns true if the status and headers were sent to the client.
+func (w *responseWriter) HeadersSent() bool {
+	return w.status != 0
+}
+
+// headersSent looks for the tracking writer of doris in the chain of wrappers to know
+// if the headers were sent. It returns false if the response is not tracked.
+func headersSent(w http.ResponseWriter) bool {
+	for {
+		switch t := w.(type) {
+		case *responseWriter:
+			return t.HeadersSent()
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
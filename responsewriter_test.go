@@ -0,0 +1,36 @@
+package doris
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseWriterTracking(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := trackResponse(rec)
+	require.False(t, headersSent(w))
+
+	n, err := io.Copy(w, strings.NewReader("hello"))
+	require.NoError(t, err)
+	require.EqualValues(t, 5, n)
+	require.True(t, headersSent(w))
+	require.Equal(t, http.StatusOK, w.Status())
+	require.EqualValues(t, 5, w.bytes)
+
+	// The tracking writer is found through other wrappers.
+	require.True(t, headersSent(&compressWriter{ResponseWriter: w}))
+}
+
+func TestErrorAfterWrite(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := trackResponse(rec)
+	_, _ = io.WriteString(w, "partial")
+	Error(w, http.StatusInternalServerError)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "partial", rec.Body.String())
+}
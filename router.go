@@ -3,47 +3,218 @@ package doris
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
-	"github.com/altipla-consulting/errors"
-	"github.com/altipla-consulting/sentry"
-	"github.com/altipla-consulting/telemetry"
 	"libs.altipla.consulting/routing"
 )
 
 type Router struct {
 	*routing.Server
+
+	port        *ServerPort
+	prefix      string
+	parent      *Router
+	middlewares []func(http.Handler) http.Handler
+}
+
+// Group returns a child router that registers all its routes under the prefix. The
+// middlewares of the group, and the ones added later with Use to the child router,
+// only apply to its routes. They run after the middlewares of the parent router.
+//
+//	admin := r.Group("/admin", requireLogin)
+//	admin.Get("/users", listUsers)
+func (r *Router) Group(prefix string, mw ...func(http.Handler) http.Handler) *Router {
+	return &Router{
+		Server:      r.Server,
+		port:        r.port,
+		prefix:      r.prefix + prefix,
+		parent:      r,
+		middlewares: mw,
+	}
+}
+
+// Get registers a new handler for GET requests to the path.
+func (r *Router) Get(path string, handler routing.Handler, opts ...RouteOption) {
+	r.Server.Get(r.prefix+path, r.routeHandler(path, Handler(HandlerError(handler)), opts))
+}
+
+// Post registers a new handler for POST requests to the path.
+func (r *Router) Post(path string, handler routing.Handler, opts ...RouteOption) {
+	r.Server.Post(r.prefix+path, r.routeHandler(path, Handler(HandlerError(handler)), opts))
+}
+
+// Put registers a new handler for PUT requests to the path.
+func (r *Router) Put(path string, handler routing.Handler, opts ...RouteOption) {
+	r.Server.Put(r.prefix+path, r.routeHandler(path, Handler(HandlerError(handler)), opts))
+}
+
+// Delete registers a new handler for DELETE requests to the path.
+func (r *Router) Delete(path string, handler routing.Handler, opts ...RouteOption) {
+	r.Server.Delete(r.prefix+path, r.routeHandler(path, Handler(HandlerError(handler)), opts))
+}
+
+// PathPrefixHandler registers a new handler for all the routes under the specified prefix.
+func (r *Router) PathPrefixHandler(path string, handler routing.Handler, opts ...RouteOption) {
+	r.Server.PathPrefixHandler(r.prefix+path, r.routeHandler(path, Handler(HandlerError(handler)), opts))
 }
 
 // PathPrefixHandlerHTTP registers a new HTTP handler for all the routes under the specified prefix.
-func (r *Router) PathPrefixHandlerHTTP(path string, handler http.Handler) {
-	r.PathPrefixHandler(path, routing.NewHandlerFromHTTP(stdMiddlewares(handler)))
+func (r *Router) PathPrefixHandlerHTTP(path string, handler http.Handler, opts ...RouteOption) {
+	r.Server.PathPrefixHandler(r.prefix+path, r.routeHandler(path, handler, opts))
 }
 
 // Handle sends all request to the standard HTTP handler.
-func (r *Router) Handle(handler http.Handler) {
-	r.PathPrefixHandlerHTTP("", stdMiddlewares(handler))
-}
-
-func stdMiddlewares(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Timeout.
-		ctx := r.Context()
-		ctx, cancel := context.WithTimeout(ctx, 29*time.Second)
-		defer cancel()
-		r = r.WithContext(ctx)
-
-		// Sentry body logging.
-		r = sentry.WithRequest(r)
-
-		// Recover panics.
-		defer func() {
-			if rec := errors.Recover(recover()); rec != nil {
-				Error(w, http.StatusInternalServerError)
-				telemetry.ReportError(r.Context(), rec)
-			}
-		}()
+func (r *Router) Handle(handler http.Handler, opts ...RouteOption) {
+	r.PathPrefixHandlerHTTP("", handler, opts...)
+}
+
+// Use adds middlewares to all the handlers of the router and its groups, including
+// the ones registered before calling it. They should be configured before serving
+// requests.
+//
+// Middlewares run in the same order they are added, after the builtin ones of doris
+// and before the middlewares of each individual route. See Builtin to configure them.
+func (r *Router) Use(mw ...func(http.Handler) http.Handler) {
+	r.middlewares = append(r.middlewares, mw...)
+}
+
+// RouteOption configures a single route of the router.
+type RouteOption func(rt *route)
+
+// WithRouteTimeout overrides the default timeout of the server for this route.
+// A zero timeout disables it completely for long running requests.
+func WithRouteTimeout(timeout time.Duration) RouteOption {
+	return func(rt *route) {
+		rt.timeout = timeout
+	}
+}
+
+// WithRouteMaxBodySize overrides the default maximum size of the request bodies of
+// the server for this route. A zero size disables the limit.
+func WithRouteMaxBodySize(size int64) RouteOption {
+	return func(rt *route) {
+		rt.maxBodySize = size
+	}
+}
+
+// WithRouteCritical marks the route as critical so it is never rejected when the
+// server sheds load. See WithLoadShedding.
+func WithRouteCritical() RouteOption {
+	return func(rt *route) {
+		rt.critical = func(r *http.Request) bool { return true }
+	}
+}
+
+// WithRouteMiddlewares adds middlewares that only apply to this route. They run
+// after the ones of the router.
+func WithRouteMiddlewares(mw ...func(http.Handler) http.Handler) RouteOption {
+	return func(rt *route) {
+		rt.middlewares = append(rt.middlewares, mw...)
+	}
+}
+
+// connectRoute marks the route as a Connect API mounted by a hub.
+func connectRoute() RouteOption {
+	return func(rt *route) {
+		rt.connect = true
+	}
+}
+
+// streamingRoute marks the route as long lived connections like WebSockets.
+func streamingRoute() RouteOption {
+	return func(rt *route) {
+		rt.streaming = true
+	}
+}
+
+// staticRoute marks the route as static files served by the router.
+func staticRoute() RouteOption {
+	return func(rt *route) {
+		rt.static = true
+	}
+}
+
+type route struct {
+	pattern     string
+	timeout     time.Duration
+	maxBodySize int64
+	middlewares []func(http.Handler) http.Handler
+	connect     bool
+	static      bool
+	streaming   bool
+	critical    func(r *http.Request) bool
+	port        *ServerPort
+}
+
+type routeKey struct{}
 
-		handler.ServeHTTP(w, r)
+// routeFromContext returns the route that is serving the request. It returns an
+// empty route outside a request handled by doris.
+func routeFromContext(ctx context.Context) *route {
+	if rt, ok := ctx.Value(routeKey{}).(*route); ok {
+		return rt
+	}
+	return new(route)
+}
+
+func (r *Router) newRoute(pattern string, opts []RouteOption) *route {
+	rt := &route{
+		pattern:     pattern,
+		timeout:     r.port.timeout,
+		maxBodySize: r.port.maxBodySize,
+		port:        r.port,
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
+}
+
+// lazyChain builds the chain of middlewares the first time the route is used, so
+// the middlewares added after registering the route are applied too.
+func (r *Router) lazyChain(rt *route, handler http.Handler) http.Handler {
+	var once sync.Once
+	var chain http.Handler
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		once.Do(func() {
+			chain = r.chain(rt, handler)
+		})
+		chain.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), routeKey{}, rt)))
 	})
 }
+
+func (r *Router) chain(rt *route, handler http.Handler) http.Handler {
+	for i := len(rt.middlewares) - 1; i >= 0; i-- {
+		handler = rt.middlewares[i](handler)
+	}
+	for group := r; group != nil; group = group.parent {
+		handler = group.chainGroup(handler)
+	}
+	for i := len(builtins) - 1; i >= 0; i-- {
+		b := builtins[i]
+		if mw, ok := r.port.builtins[b.name]; ok {
+			if mw != nil {
+				handler = mw(handler)
+			}
+			continue
+		}
+		handler = b.fn(r.port, rt, handler)
+	}
+	return handler
+}
+
+// chainGroup applies the middlewares of this router to the handler.
+func (r *Router) chainGroup(handler http.Handler) http.Handler {
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		handler = r.middlewares[i](handler)
+	}
+	return handler
+}
+
+// routeHandler applies the builtin middlewares and the ones of the router, its
+// parents and the route to the handler.
+func (r *Router) routeHandler(path string, handler http.Handler, opts []RouteOption) routing.Handler {
+	rt := r.newRoute(r.prefix+path, opts)
+	return routing.NewHandlerFromHTTP(r.lazyChain(rt, handler))
+}
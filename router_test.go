@@ -0,0 +1,124 @@
+package doris_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/altipla-consulting/doris"
+)
+
+func recordMiddleware(calls *[]string, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*calls = append(*calls, name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRouterMiddlewaresOrder(t *testing.T) {
+	var calls []string
+
+	r := doris.NewServer()
+	r.Use(recordMiddleware(&calls, "router1"))
+	r.PathPrefixHandlerHTTP("/foo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}), doris.WithRouteMiddlewares(recordMiddleware(&calls, "route")))
+	r.Use(recordMiddleware(&calls, "router2"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	require.Equal(t, []string{"router1", "router2", "route", "handler"}, calls)
+}
+
+func TestRouterReplacedBuiltin(t *testing.T) {
+	var calls []string
+
+	r := doris.NewServer(doris.WithReplacedBuiltin(doris.BuiltinRecover, recordMiddleware(&calls, "recover")))
+	r.PathPrefixHandlerHTTP("/foo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	require.Equal(t, []string{"recover", "handler"}, calls)
+}
+
+func TestRouterRequestID(t *testing.T) {
+	var id string
+
+	r := doris.NewServer()
+	r.PathPrefixHandlerHTTP("/foo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id = doris.RequestID(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/foo", nil))
+	require.NotEmpty(t, id)
+	require.Equal(t, id, w.Header().Get(doris.RequestIDHeader))
+
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set(doris.RequestIDHeader, "lb-generated-id")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, "lb-generated-id", id)
+	require.Equal(t, "lb-generated-id", w.Header().Get(doris.RequestIDHeader))
+}
+
+func TestRouterGroup(t *testing.T) {
+	var calls []string
+
+	r := doris.NewServer()
+	r.Use(recordMiddleware(&calls, "router"))
+	admin := r.Group("/admin", recordMiddleware(&calls, "group"))
+	admin.PathPrefixHandlerHTTP("/foo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, "handler")
+	}))
+	admin.Get("/bar", func(w http.ResponseWriter, r *http.Request) error {
+		calls = append(calls, "handler-error")
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/foo", nil))
+	require.Equal(t, []string{"router", "group", "handler"}, calls)
+
+	calls = nil
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/bar", nil))
+	require.Equal(t, []string{"router", "group", "handler-error"}, calls)
+}
+
+func TestRouterGroupRootMiddlewares(t *testing.T) {
+	var loadErr error
+
+	r := doris.NewServer()
+	r.Use(doris.Sessions())
+	admin := r.Group("/admin", func(next http.Handler) http.Handler {
+		return next
+	})
+	admin.Get("/users", func(w http.ResponseWriter, r *http.Request) error {
+		_, loadErr = doris.LoadSession(r.Context())
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users", nil))
+	require.NoError(t, loadErr)
+}
+
+func TestRouterInternalEndpoints(t *testing.T) {
+	r := doris.NewServer()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "login required", http.StatusUnauthorized)
+		})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
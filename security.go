@@ -0,0 +1,158 @@
+package doris
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/altipla-consulting/env"
+)
+
+// DefaultContentSecurityPolicy only allows resources from the same origin and
+// inline code with the nonce of the request. See CSPNonce.
+const DefaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'nonce-{nonce}'; " +
+	"style-src 'self' 'nonce-{nonce}'; " +
+	"img-src 'self' data:; " +
+	"object-src 'none'; " +
+	"base-uri 'self'; " +
+	"form-action 'self'; " +
+	"frame-ancestors 'none'"
+
+// SecurityOption configures the security headers middleware.
+type SecurityOption func(cnf *securityConfig)
+
+// WithHSTS configures the time browsers should only connect using HTTPS. By default
+// it is 2 years including subdomains. A zero duration disables the header.
+// The header is never sent in local environments.
+func WithHSTS(maxAge time.Duration, includeSubdomains bool) SecurityOption {
+	return func(cnf *securityConfig) {
+		cnf.hsts = ""
+		if maxAge > 0 {
+			cnf.hsts = fmt.Sprintf("max-age=%d", int(maxAge.Seconds()))
+			if includeSubdomains {
+				cnf.hsts += "; includeSubDomains"
+			}
+		}
+	}
+}
+
+// WithReferrerPolicy changes the Referrer-Policy header. By default it is
+// "strict-origin-when-cross-origin". An empty value disables the header.
+func WithReferrerPolicy(policy string) SecurityOption {
+	return func(cnf *securityConfig) {
+		cnf.referrer = policy
+	}
+}
+
+// WithFrameOptions changes the X-Frame-Options header. By default it is "DENY".
+// An empty value disables the header.
+func WithFrameOptions(options string) SecurityOption {
+	return func(cnf *securityConfig) {
+		cnf.frame = options
+	}
+}
+
+// WithPermissionsPolicy changes the Permissions-Policy header. By default it disables
+// the camera, microphone, geolocation and payment APIs. An empty value disables the header.
+func WithPermissionsPolicy(policy string) SecurityOption {
+	return func(cnf *securityConfig) {
+		cnf.permissions = policy
+	}
+}
+
+// WithContentSecurityPolicy changes the Content-Security-Policy header. The text
+// {nonce} will be replaced with the nonce of each request. By default it is
+// DefaultContentSecurityPolicy. An empty value disables the header.
+func WithContentSecurityPolicy(policy string) SecurityOption {
+	return func(cnf *securityConfig) {
+		cnf.csp = policy
+	}
+}
+
+// WithCSPReportOnly sends the Content-Security-Policy in report only mode to test
+// it before enforcing the policy.
+func WithCSPReportOnly() SecurityOption {
+	return func(cnf *securityConfig) {
+		cnf.cspReportOnly = true
+	}
+}
+
+type securityConfig struct {
+	hsts          string
+	referrer      string
+	frame         string
+	permissions   string
+	csp           string
+	cspReportOnly bool
+}
+
+type cspNonceKey struct{}
+
+// CSPNonce returns the nonce of the Content-Security-Policy of the request. Templates
+// should add it to the nonce attribute of inline scripts and styles. Responses that
+// use it are not stored by Cache.
+// It returns an empty string if the security headers middleware is not configured.
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	if nonce != "" {
+		preventCache(ctx)
+	}
+	return nonce
+}
+
+// SecurityHeaders returns a middleware that sets the recommended security headers
+// in all responses, including a Content-Security-Policy with a different nonce for
+// each request.
+func SecurityHeaders(opts ...SecurityOption) func(http.Handler) http.Handler {
+	cnf := &securityConfig{
+		hsts:        "max-age=63072000; includeSubDomains",
+		referrer:    "strict-origin-when-cross-origin",
+		frame:       "DENY",
+		permissions: "camera=(), microphone=(), geolocation=(), payment=()",
+		csp:         DefaultContentSecurityPolicy,
+	}
+	for _, opt := range opts {
+		opt(cnf)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			if cnf.hsts != "" && !env.IsLocal() {
+				h.Set("Strict-Transport-Security", cnf.hsts)
+			}
+			if cnf.referrer != "" {
+				h.Set("Referrer-Policy", cnf.referrer)
+			}
+			if cnf.frame != "" {
+				h.Set("X-Frame-Options", cnf.frame)
+			}
+			if cnf.permissions != "" {
+				h.Set("Permissions-Policy", cnf.permissions)
+			}
+			if cnf.csp != "" {
+				nonce := generateNonce()
+				header := "Content-Security-Policy"
+				if cnf.cspReportOnly {
+					header = "Content-Security-Policy-Report-Only"
+				}
+				h.Set(header, strings.ReplaceAll(cnf.csp, "{nonce}", nonce))
+				r = r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, nonce))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func generateNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
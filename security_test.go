@@ -0,0 +1,50 @@
+package doris
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	var nonce string
+	handler := SecurityHeaders()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonce(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.NotEmpty(t, nonce)
+	require.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	require.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+	require.Contains(t, w.Header().Get("Content-Security-Policy"), "'nonce-"+nonce+"'")
+}
+
+func TestSecurityHeadersErrorPage(t *testing.T) {
+	handler := SecurityHeaders()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Error(w, http.StatusNotFound)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	policy := w.Header().Get("Content-Security-Policy")
+	require.Contains(t, policy, "https://cdnjs.cloudflare.com")
+	start := strings.Index(policy, "'nonce-") + len("'nonce-")
+	nonce := policy[start : start+strings.Index(policy[start:], "'")]
+	require.Contains(t, w.Body.String(), `<script type="text/javascript" nonce="`+nonce+`">`)
+}
+
+func TestErrorPageWithoutInlineHandlers(t *testing.T) {
+	// The policy of the error page does not allow javascript: URLs or inline handlers.
+	for _, status := range []int{400, 401, 403, 404, 405, 408, 412, 413, 429, 500, 503, 504} {
+		w := httptest.NewRecorder()
+		Error(w, status)
+		require.NotContains(t, w.Body.String(), "javascript:", "status %d", status)
+		require.NotContains(t, w.Body.String(), " onclick=", "status %d", status)
+	}
+}
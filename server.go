@@ -6,14 +6,19 @@ import (
 	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/VictoriaMetrics/metrics"
 	"github.com/altipla-consulting/env"
 	"github.com/altipla-consulting/errors"
 	"github.com/altipla-consulting/telemetry/logging"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"golang.org/x/sync/errgroup"
@@ -27,8 +32,12 @@ type Server struct {
 	cancel context.CancelFunc
 	grp    *errgroup.Group
 
-	ports      []*ServerPort
-	shutdownCh chan struct{}
+	ports       []*ServerPort
+	shutdownCh  chan struct{}
+	maintenance *atomic.Bool
+
+	tracing        *tracingConfig
+	tracerProvider *sdktrace.TracerProvider
 }
 
 // NewServer creates a new root server in the default port. It won't start until
@@ -38,21 +47,33 @@ func NewServer(opts ...Option) *Server {
 	grp, ctx := errgroup.WithContext(ctx)
 
 	server := &Server{
-		ctx:        ctx,
-		cancel:     cancel,
-		grp:        grp,
-		shutdownCh: make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+		grp:         grp,
+		shutdownCh:  make(chan struct{}),
+		maintenance: new(atomic.Bool),
+	}
+	if enabled, _ := strconv.ParseBool(os.Getenv("MAINTENANCE_MODE")); enabled {
+		server.SetMaintenance(true)
 	}
 	server.ServerPort = newServerPort(server, opts, false)
+	if server.tracing != nil {
+		if err := server.configureTracing(server.tracing); err != nil {
+			// NewServer cannot return errors, invalid options panic like the rest.
+			panic(err)
+		}
+	}
 
 	if env.IsLocal() {
-		server.Get("/metrics", metricsHandler)
+		server.Server.Get("/metrics", metricsHandler)
+		server.registerMaintenance(server.ServerPort)
 	} else {
 		// Register an internal port for health checks and metrics.
 		// It should be first to shutdown it first too and disconnect live connections
 		// as soon as possible when restarting the app.
 		internal := newServerPort(server, opts, true)
-		internal.Get("/metrics", metricsHandler)
+		internal.Server.Get("/metrics", metricsHandler)
+		server.registerMaintenance(internal)
 		server.ports = append(server.ports, internal)
 	}
 
@@ -83,6 +104,8 @@ func (server *Server) GoBackground(fn func(ctx context.Context) error) {
 // Register a new child server in a different port.
 func (server *Server) RegisterPort(port string, opts ...Option) *ServerPort {
 	sp := newServerPort(nil, append(opts, WithPort(port)), false)
+	sp.maintenance.enabled = server.maintenance
+	sp.ctx = server.ctx
 	server.ports = append(server.ports, sp)
 	return sp
 }
@@ -122,12 +145,23 @@ func (server *Server) Serve() {
 	for _, sp := range server.ports {
 		sp.shutdown(shutdownctx)
 	}
+	if server.tracerProvider != nil {
+		if err := server.tracerProvider.Shutdown(shutdownctx); err != nil {
+			slog.Error("Cannot flush traces", slog.String("error", err.Error()))
+		}
+	}
 
 	if err := server.grp.Wait(); err != nil {
 		logging.Fatal("Error starting the server", err)
 	}
 }
 
+func (server *Server) registerMaintenance(sp *ServerPort) {
+	sp.Server.Get("/maintenance", server.maintenanceHandler)
+	sp.Server.Post("/maintenance/enable", server.enableMaintenanceHandler)
+	sp.Server.Post("/maintenance/disable", server.disableMaintenanceHandler)
+}
+
 // Close gracefully shuts down the server using the same procedure as when receiving a close signal.
 func (server *Server) Close() {
 	select {
@@ -141,12 +175,23 @@ type ServerPort struct {
 	*Router
 
 	// Configurations from options passed when initializing the port.
-	http     []routing.ServerOption
-	listener net.Listener
-	port     string
+	http            []routing.ServerOption
+	listener        net.Listener
+	port            string
+	proxyProtocol   *proxyProtocolConfig
+	trustedProxies  []netip.Prefix
+	forwardedHeader ForwardedHeader
+	timeout         time.Duration
+	maxBodySize     int64
+	builtins        map[Builtin]func(http.Handler) http.Handler
+	accessLog       *accessLogConfig
+	maintenance     *maintenanceConfig
+	loadShed        *concurrencyLimiter
+	ctx             context.Context
 
 	// Internal initialization when serving to shutdown it down afterwards.
-	web *http.Server
+	web     *http.Server
+	sockets socketTracker
 }
 
 func newServerPort(s *Server, opts []Option, internal bool) *ServerPort {
@@ -154,7 +199,24 @@ func newServerPort(s *Server, opts []Option, internal bool) *ServerPort {
 		http: []routing.ServerOption{
 			routing.WithSentry(os.Getenv("SENTRY_DSN")),
 		},
-		port: "8080",
+		port:            "8080",
+		forwardedHeader: ForwardedHeaderXForwardedFor,
+		timeout:         DefaultTimeout,
+		maxBodySize:     DefaultMaxBodySize,
+		builtins:        make(map[Builtin]func(http.Handler) http.Handler),
+		accessLog: &accessLogConfig{
+			sampling: 1,
+			slow:     5 * time.Second,
+			exclude:  []string{"/health", "/metrics"},
+		},
+		maintenance: &maintenanceConfig{
+			enabled:    new(atomic.Bool),
+			retryAfter: 5 * time.Minute,
+		},
+	}
+	if s != nil {
+		sp.maintenance.enabled = s.maintenance
+		sp.ctx = s.ctx
 	}
 	if p := os.Getenv("PORT"); p != "" {
 		sp.port = p
@@ -165,12 +227,18 @@ func newServerPort(s *Server, opts []Option, internal bool) *ServerPort {
 	for _, opt := range opts {
 		opt(s, sp, internal)
 	}
+	if sp.loadShed != nil {
+		sp.loadShed.init()
+		metrics.GetOrCreateGauge(fmt.Sprintf(`doris_http_concurrency_limit{port=%q}`, sp.port), sp.loadShed.currentLimit)
+	}
 
 	sp.Router = &Router{
 		Server: routing.NewServer(sp.http...),
+		port:   sp,
 	}
 
-	sp.Get("/health", healthHandler)
+	// The internal endpoints skip the middlewares of the application.
+	sp.Server.Get("/health", healthHandler)
 
 	return sp
 }
@@ -186,20 +254,26 @@ func (sp *ServerPort) serve(grp *errgroup.Group) {
 	}
 
 	grp.Go(func() error {
-		if sp.listener != nil {
-			if err := sp.web.Serve(sp.listener); err != nil && !isClosingError(err) {
-				return errors.Errorf("failed to serve: %w", err)
-			}
-		} else {
-			if err := sp.web.ListenAndServe(); err != nil && !isClosingError(err) {
-				return errors.Errorf("failed to serve: %w", err)
+		listener := sp.listener
+		if listener == nil {
+			var err error
+			listener, err = net.Listen("tcp", sp.web.Addr)
+			if err != nil {
+				return errors.Errorf("failed to listen: %w", err)
 			}
 		}
+		if sp.proxyProtocol != nil {
+			listener = &proxyListener{Listener: listener, cnf: sp.proxyProtocol}
+		}
+		if err := sp.web.Serve(listener); err != nil && !isClosingError(err) {
+			return errors.Errorf("failed to serve: %w", err)
+		}
 		return nil
 	})
 }
 
 func (sp *ServerPort) shutdown(ctx context.Context) {
+	sp.sockets.closeAll(ctx)
 	_ = sp.web.Shutdown(ctx)
 	_ = sp.web.Close()
 }
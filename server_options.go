@@ -2,6 +2,8 @@ package doris
 
 import (
 	"net"
+	"net/http"
+	"time"
 
 	"libs.altipla.consulting/routing"
 )
@@ -48,3 +50,161 @@ func WithInternal(opts ...Option) Option {
 		}
 	}
 }
+
+// WithProxyProtocol parses the PROXY protocol v1 and v2 headers sent by TCP load
+// balancers to know the real address of the client. Only connections coming from
+// the trusted IPs or CIDRs will be inspected; the rest are served as usual. Trusted
+// connections without the header are closed.
+func WithProxyProtocol(trusted ...string) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		if sp.proxyProtocol == nil {
+			sp.proxyProtocol = &proxyProtocolConfig{timeout: 5 * time.Second}
+		}
+		sp.proxyProtocol.trusted = append(sp.proxyProtocol.trusted, parsePrefixes(trusted)...)
+	}
+}
+
+// WithProxyProtocolTimeout changes the maximum time to wait for the PROXY protocol
+// header after accepting a connection. By default it is 5 seconds.
+func WithProxyProtocolTimeout(timeout time.Duration) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		if sp.proxyProtocol == nil {
+			sp.proxyProtocol = new(proxyProtocolConfig)
+		}
+		sp.proxyProtocol.timeout = timeout
+	}
+}
+
+// WithTrustedProxies configures the IPs or CIDRs of the proxies in front of the
+// application. Requests coming from them will use the X-Forwarded-* headers to know
+// the real client IP, scheme and host. See ClientIP and WithForwardedHeader.
+func WithTrustedProxies(cidrs ...string) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.trustedProxies = append(sp.trustedProxies, parsePrefixes(cidrs)...)
+	}
+}
+
+// WithForwardedHeader configures the header the trusted proxies write with the
+// address of the client. Only that one is read; proxies pass the other one through
+// untouched and any client could send it to spoof its IP. By default it is
+// ForwardedHeaderXForwardedFor.
+func WithForwardedHeader(header ForwardedHeader) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.forwardedHeader = header
+	}
+}
+
+// WithTimeout changes the default timeout of the requests. By default it is
+// DefaultTimeout. A zero timeout disables it completely.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.timeout = timeout
+	}
+}
+
+// WithMaxBodySize changes the default maximum size of the request bodies. By default
+// it is DefaultMaxBodySize. A zero size disables the limit completely.
+func WithMaxBodySize(size int64) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.maxBodySize = size
+	}
+}
+
+// WithMaintenanceAllowlist configures IPs or CIDRs that can access the application
+// while it is in maintenance mode, for example to verify it before reopening.
+func WithMaintenanceAllowlist(cidrs ...string) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.maintenance.allowed = append(sp.maintenance.allowed, parsePrefixes(cidrs)...)
+	}
+}
+
+// WithMaintenancePaths configures path prefixes that are still served while the
+// application is in maintenance mode.
+func WithMaintenancePaths(prefixes ...string) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.maintenance.paths = append(sp.maintenance.paths, prefixes...)
+	}
+}
+
+// WithMaintenanceRetryAfter changes the time clients are asked to wait before
+// retrying while the application is in maintenance mode. By default it is 5 minutes.
+func WithMaintenanceRetryAfter(retryAfter time.Duration) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.maintenance.retryAfter = retryAfter
+	}
+}
+
+// WithLoadShedding enables an adaptive limit of concurrent requests that rejects the
+// excess load fast with a 503 error page or an Unavailable error in Connect APIs when
+// the latency of the application grows. Health checks and critical routes are never
+// rejected. See WithRouteCritical and WithCriticalProcedures.
+func WithLoadShedding(opts ...LoadShedOption) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		if internal {
+			return
+		}
+		sp.loadShed = newConcurrencyLimiter()
+		for _, opt := range opts {
+			opt(sp.loadShed)
+		}
+	}
+}
+
+// WithTracing enables the export of OpenTelemetry traces with spans for every HTTP
+// request and Connect call. It can only be used at the server level.
+func WithTracing(opts ...TracingOption) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		if s == nil {
+			panic("WithTracing can only be used at the server level")
+		}
+		if internal {
+			return
+		}
+		s.tracing = &tracingConfig{ratio: 1}
+		for _, opt := range opts {
+			opt(s.tracing)
+		}
+	}
+}
+
+// WithoutBuiltin disables some of the standard middlewares of the server.
+func WithoutBuiltin(builtins ...Builtin) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		for _, b := range builtins {
+			sp.builtins[b] = nil
+		}
+	}
+}
+
+// WithReplacedBuiltin replaces one of the standard middlewares of the server with a
+// custom implementation. It will run in the same position of the chain.
+func WithReplacedBuiltin(builtin Builtin, mw func(http.Handler) http.Handler) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.builtins[builtin] = mw
+	}
+}
+
+// WithAccessLogSampling configures the fraction of successful requests that will
+// emit an access log, from 0 to 1. Failed and slow requests are always logged.
+// By default all requests are logged.
+func WithAccessLogSampling(rate float64) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.accessLog.sampling = rate
+	}
+}
+
+// WithSlowRequestThreshold configures the latency from which requests are logged
+// as warnings. By default it is 5 seconds. A zero threshold disables it.
+func WithSlowRequestThreshold(threshold time.Duration) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.accessLog.slow = threshold
+	}
+}
+
+// WithAccessLogExclusions adds paths that won't emit access logs. Paths ending in
+// a slash exclude all the routes under them. By default /health and /metrics are excluded.
+func WithAccessLogExclusions(paths ...string) Option {
+	return func(s *Server, sp *ServerPort, internal bool) {
+		sp.accessLog.exclude = append(sp.accessLog.exclude, paths...)
+	}
+}
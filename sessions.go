@@ -0,0 +1,398 @@
+package doris
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/altipla-consulting/env"
+	"github.com/altipla-consulting/errors"
+)
+
+// SessionStore keeps the data of the sessions in the server. The cookie of the
+// client only contains the encrypted ID of the session when a store is configured.
+type SessionStore interface {
+	// Load returns the data of the session, or nil if it does not exist or has expired.
+	Load(ctx context.Context, id string) ([]byte, error)
+
+	// Save stores the data of the session until it expires.
+	Save(ctx context.Context, id string, data []byte, expires time.Time) error
+
+	// Delete removes the session from the store.
+	Delete(ctx context.Context, id string) error
+}
+
+// SessionOption configures the sessions middleware.
+type SessionOption func(cnf *sessionConfig)
+
+// WithSessionKeys configures the secret keys used to encrypt and authenticate the
+// cookies. The first one encrypts new cookies and all of them can read existing
+// ones, so keys can be rotated adding a new one at the beginning of the list. All
+// the instances of the application should share the same keys. By default a random
+// key is generated when the application starts.
+func WithSessionKeys(keys ...[]byte) SessionOption {
+	return func(cnf *sessionConfig) {
+		cnf.keys = keys
+	}
+}
+
+// WithSessionStore keeps the data of the sessions in the server instead of the cookie.
+func WithSessionStore(store SessionStore) SessionOption {
+	return func(cnf *sessionConfig) {
+		cnf.store = store
+	}
+}
+
+// WithSessionMaxAge configures the time sessions last since they were last saved.
+// By default it is 30 days.
+func WithSessionMaxAge(maxAge time.Duration) SessionOption {
+	return func(cnf *sessionConfig) {
+		cnf.maxAge = maxAge
+	}
+}
+
+// WithSessionSameSite changes the SameSite attribute of the cookie. By default it is Lax.
+func WithSessionSameSite(sameSite http.SameSite) SessionOption {
+	return func(cnf *sessionConfig) {
+		cnf.sameSite = sameSite
+	}
+}
+
+type sessionConfig struct {
+	keys     [][]byte
+	store    SessionStore
+	maxAge   time.Duration
+	sameSite http.SameSite
+
+	name  string
+	aeads []cipher.AEAD
+}
+
+type sessionKey struct{}
+
+// Sessions returns a middleware that makes the session of the client available to
+// the handlers with LoadSession.
+//
+// Sessions are kept in a cookie encrypted and authenticated with AES-GCM, or in a
+// SessionStore if configured. The cookie is HttpOnly, SameSite=Lax and Secure outside
+// the local environment.
+func Sessions(opts ...SessionOption) func(http.Handler) http.Handler {
+	cnf := &sessionConfig{
+		maxAge:   30 * 24 * time.Hour,
+		sameSite: http.SameSiteLaxMode,
+		name:     "__Host-session",
+	}
+	for _, opt := range opts {
+		opt(cnf)
+	}
+	if env.IsLocal() {
+		cnf.name = "session"
+	}
+	if len(cnf.keys) == 0 {
+		key := make([]byte, 32)
+		_, _ = rand.Read(key)
+		cnf.keys = [][]byte{key}
+	}
+	for _, key := range cnf.keys {
+		// Derive a key of the correct size for AES-256 from any secret.
+		derived := sha256.Sum256(key)
+		block, err := aes.NewCipher(derived[:])
+		if err != nil {
+			panic(err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(err)
+		}
+		cnf.aeads = append(cnf.aeads, aead)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state := &sessionState{cnf: cnf, r: r}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, state)))
+		})
+	}
+}
+
+// sessionState loads the session the first time it is requested by the handler.
+type sessionState struct {
+	cnf *sessionConfig
+	r   *http.Request
+
+	once    sync.Once
+	session *Session
+	err     error
+}
+
+// LoadSession returns the session of the client of the request. It returns a new
+// empty session if the client does not have one or it has expired.
+//
+// Changes to the session should be sent to the client calling Save before writing
+// the response.
+func LoadSession(ctx context.Context) (*Session, error) {
+	state, ok := ctx.Value(sessionKey{}).(*sessionState)
+	if !ok {
+		return nil, errors.Errorf("sessions middleware not configured")
+	}
+	state.once.Do(func() {
+		state.session, state.err = state.cnf.load(ctx, state.r)
+	})
+	return state.session, state.err
+}
+
+type sessionData struct {
+	Values  map[string]string `json:"values,omitempty"`
+	Flashes []string          `json:"flashes,omitempty"`
+}
+
+// Session contains the data of the client saved between requests.
+type Session struct {
+	cnf  *sessionConfig
+	ctx  context.Context
+	id   string
+	old  string
+	data sessionData
+}
+
+func (cnf *sessionConfig) load(ctx context.Context, r *http.Request) (*Session, error) {
+	session := &Session{cnf: cnf, ctx: ctx}
+
+	cookie, err := r.Cookie(cnf.name)
+	if err != nil {
+		return session, nil
+	}
+	payload, ok := cnf.open(cookie.Value)
+	if !ok {
+		return session, nil
+	}
+
+	if cnf.store != nil {
+		session.id = string(payload)
+		payload, err = cnf.store.Load(ctx, session.id)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if payload == nil {
+			session.id = ""
+			return session, nil
+		}
+	}
+	if err := json.Unmarshal(payload, &session.data); err != nil {
+		logger(ctx).Warn("Cannot decode session", "error", err.Error())
+		return session, nil
+	}
+	return session, nil
+}
+
+// Get returns a value of the session, or an empty string if it does not exist.
+func (session *Session) Get(key string) string {
+	return session.data.Values[key]
+}
+
+// Set changes a value of the session.
+func (session *Session) Set(key, value string) {
+	if session.data.Values == nil {
+		session.data.Values = make(map[string]string)
+	}
+	session.data.Values[key] = value
+}
+
+// Delete removes a value of the session.
+func (session *Session) Delete(key string) {
+	delete(session.data.Values, key)
+}
+
+// AddFlash adds a message that will be shown to the client in the next request.
+func (session *Session) AddFlash(msg string) {
+	session.data.Flashes = append(session.data.Flashes, msg)
+}
+
+// Flashes returns the pending messages and removes them from the session.
+func (session *Session) Flashes() []string {
+	flashes := session.data.Flashes
+	session.data.Flashes = nil
+	return flashes
+}
+
+// Clear removes all the data of the session. The cookie is removed from the
+// client when saving an empty session.
+func (session *Session) Clear() {
+	session.data = sessionData{}
+}
+
+// Renew changes the ID of the session in the store keeping its data. It should be
+// called when the privileges of the client change, for example after a login, to
+// prevent session fixation attacks.
+func (session *Session) Renew() {
+	if session.id != "" && session.old == "" {
+		session.old = session.id
+	}
+	session.id = ""
+}
+
+// Save sends the session to the client. It should be called before writing the body
+// of the response.
+func (session *Session) Save(w http.ResponseWriter) error {
+	cnf := session.cnf
+	cookie := &http.Cookie{
+		Name:     cnf.name,
+		Path:     "/",
+		Secure:   !env.IsLocal(),
+		HttpOnly: true,
+		SameSite: cnf.sameSite,
+	}
+
+	if cnf.store != nil && session.old != "" {
+		if err := cnf.store.Delete(session.ctx, session.old); err != nil {
+			return errors.Trace(err)
+		}
+		session.old = ""
+	}
+
+	if len(session.data.Values) == 0 && len(session.data.Flashes) == 0 {
+		if cnf.store != nil && session.id != "" {
+			if err := cnf.store.Delete(session.ctx, session.id); err != nil {
+				return errors.Trace(err)
+			}
+			session.id = ""
+		}
+		cookie.MaxAge = -1
+		http.SetCookie(w, cookie)
+		return nil
+	}
+
+	payload, err := json.Marshal(session.data)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	expires := time.Now().Add(cnf.maxAge)
+	if cnf.store != nil {
+		if session.id == "" {
+			id := make([]byte, 24)
+			_, _ = rand.Read(id)
+			session.id = base64.RawURLEncoding.EncodeToString(id)
+		}
+		if err := cnf.store.Save(session.ctx, session.id, payload, expires); err != nil {
+			return errors.Trace(err)
+		}
+		payload = []byte(session.id)
+	}
+
+	cookie.Value = cnf.seal(payload, expires)
+	cookie.MaxAge = int(cnf.maxAge.Seconds())
+	if len(cookie.String()) > 4096 {
+		return errors.Errorf("session too large for a cookie: %d bytes", len(cookie.String()))
+	}
+	http.SetCookie(w, cookie)
+	return nil
+}
+
+// seal encrypts the payload with its expiration using the first key. The name of
+// the cookie is authenticated too to avoid moving values between cookies.
+func (cnf *sessionConfig) seal(payload []byte, expires time.Time) string {
+	plaintext := binary.BigEndian.AppendUint64(nil, uint64(expires.Unix()))
+	plaintext = append(plaintext, payload...)
+
+	aead := cnf.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	_, _ = rand.Read(nonce)
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte(cnf.name)))
+}
+
+// open decrypts the value of the cookie with any of the keys, and checks it has
+// not expired yet.
+func (cnf *sessionConfig) open(value string) ([]byte, bool) {
+	ciphertext, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	for _, aead := range cnf.aeads {
+		if len(ciphertext) < aead.NonceSize() {
+			continue
+		}
+		nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, sealed, []byte(cnf.name))
+		if err != nil || len(plaintext) < 8 {
+			continue
+		}
+		if time.Now().Unix() > int64(binary.BigEndian.Uint64(plaintext)) {
+			return nil, false
+		}
+		return plaintext[8:], true
+	}
+	return nil, false
+}
+
+// MemorySessionStore keeps the sessions in the memory of the instance. It is useful
+// for development and applications with a single instance.
+type MemorySessionStore struct {
+	mu        sync.Mutex
+	sessions  map[string]memorySession
+	lastSweep time.Time
+}
+
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemorySessionStore creates a new empty store in memory.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions:  make(map[string]memorySession),
+		lastSweep: time.Now(),
+	}
+}
+
+// Load implements SessionStore.
+func (store *MemorySessionStore) Load(ctx context.Context, id string) ([]byte, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	session, ok := store.sessions[id]
+	if !ok || time.Now().After(session.expires) {
+		return nil, nil
+	}
+	return session.data, nil
+}
+
+// Save implements SessionStore.
+func (store *MemorySessionStore) Save(ctx context.Context, id string, data []byte, expires time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.sweep(time.Now())
+	store.sessions[id] = memorySession{data: data, expires: expires}
+	return nil
+}
+
+// Delete implements SessionStore.
+func (store *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.sessions, id)
+	return nil
+}
+
+// sweep removes the expired sessions from time to time to keep the memory bounded.
+func (store *MemorySessionStore) sweep(now time.Time) {
+	if now.Sub(store.lastSweep) < time.Minute {
+		return
+	}
+	store.lastSweep = now
+	for id, session := range store.sessions {
+		if now.After(session.expires) {
+			delete(store.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package doris
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sessionRequest runs fn inside the sessions middleware with the cookies, returning
+// the cookies sent back to the client.
+func sessionRequest(t *testing.T, mw func(http.Handler) http.Handler, cookies []*http.Cookie, fn func(session *Session)) []*http.Cookie {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := LoadSession(r.Context())
+		require.NoError(t, err)
+		fn(session)
+		require.NoError(t, session.Save(w))
+	})).ServeHTTP(w, r)
+	return w.Result().Cookies()
+}
+
+func TestSessionsCookie(t *testing.T) {
+	mw := Sessions(WithSessionKeys([]byte("foo")))
+
+	cookies := sessionRequest(t, mw, nil, func(session *Session) {
+		session.Set("user", "alice")
+		session.AddFlash("welcome")
+	})
+	require.Len(t, cookies, 1)
+	require.True(t, cookies[0].HttpOnly)
+	require.NotContains(t, cookies[0].Value, "alice")
+
+	cookies = sessionRequest(t, mw, cookies, func(session *Session) {
+		require.Equal(t, "alice", session.Get("user"))
+		require.Equal(t, []string{"welcome"}, session.Flashes())
+	})
+	sessionRequest(t, mw, cookies, func(session *Session) {
+		require.Equal(t, "alice", session.Get("user"))
+		require.Empty(t, session.Flashes())
+	})
+}
+
+func TestSessionsKeyRotation(t *testing.T) {
+	cookies := sessionRequest(t, Sessions(WithSessionKeys([]byte("old"))), nil, func(session *Session) {
+		session.Set("user", "alice")
+	})
+
+	sessionRequest(t, Sessions(WithSessionKeys([]byte("new"), []byte("old"))), cookies, func(session *Session) {
+		require.Equal(t, "alice", session.Get("user"))
+	})
+	sessionRequest(t, Sessions(WithSessionKeys([]byte("new"))), cookies, func(session *Session) {
+		require.Empty(t, session.Get("user"))
+	})
+}
+
+func TestSessionsTampered(t *testing.T) {
+	mw := Sessions(WithSessionKeys([]byte("foo")))
+	cookies := sessionRequest(t, mw, nil, func(session *Session) {
+		session.Set("user", "alice")
+	})
+	cookies[0].Value = "x" + cookies[0].Value[1:]
+	sessionRequest(t, mw, cookies, func(session *Session) {
+		require.Empty(t, session.Get("user"))
+	})
+}
+
+func TestSessionsStore(t *testing.T) {
+	store := NewMemorySessionStore()
+	mw := Sessions(WithSessionKeys([]byte("foo")), WithSessionStore(store))
+
+	cookies := sessionRequest(t, mw, nil, func(session *Session) {
+		session.Set("user", "alice")
+	})
+	require.Len(t, store.sessions, 1)
+
+	cookies = sessionRequest(t, mw, cookies, func(session *Session) {
+		require.Equal(t, "alice", session.Get("user"))
+		session.Renew()
+	})
+	require.Len(t, store.sessions, 1)
+
+	cookies = sessionRequest(t, mw, cookies, func(session *Session) {
+		require.Equal(t, "alice", session.Get("user"))
+		session.Clear()
+	})
+	require.Empty(t, store.sessions)
+	require.Equal(t, -1, cookies[0].MaxAge)
+}
@@ -0,0 +1,221 @@
+package doris
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+)
+
+// SSEEvent is a message sent to the client of an event stream.
+type SSEEvent struct {
+	// ID is sent back by the browser in the Last-Event-ID header when reconnecting.
+	ID string
+
+	// Event is the type of the event. Browsers dispatch events without a type as "message".
+	Event string
+
+	// Data is the content of the event. It can contain several lines.
+	Data string
+
+	// Retry changes the time the browser waits before reconnecting.
+	Retry time.Duration
+}
+
+// SSEOption configures an event stream.
+type SSEOption func(stream *SSEStream)
+
+// WithSSEHeartbeat changes the interval between the comments sent to keep the
+// connection alive through proxies. By default it is 15 seconds. A zero interval
+// disables them.
+func WithSSEHeartbeat(interval time.Duration) SSEOption {
+	return func(stream *SSEStream) {
+		stream.heartbeat = interval
+	}
+}
+
+// SSEStream sends Server-Sent Events to the client.
+type SSEStream struct {
+	w         http.ResponseWriter
+	rc        *http.ResponseController
+	heartbeat time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu  sync.Mutex
+	err error
+}
+
+// SSE registers a GET handler for an event stream in the path. The route is exempted
+// from the request timeout and does not count towards the load shedding limit while
+// the stream is open. The stream started by the handler with the SSE function is
+// closed when it returns.
+func (r *Router) SSE(path string, handler HandlerError, opts ...RouteOption) {
+	opts = append([]RouteOption{WithRouteTimeout(0), streamingRoute()}, opts...)
+	r.Get(path, func(w http.ResponseWriter, req *http.Request) error {
+		var stream *SSEStream
+		defer func() {
+			if stream != nil {
+				stream.Close()
+			}
+		}()
+		return handler(w, req.WithContext(context.WithValue(req.Context(), sseStreamKey{}, &stream)))
+	}, opts...)
+}
+
+type sseStreamKey struct{}
+
+// SSE starts an event stream in the response. Its context is canceled when the client
+// disconnects or the server shuts down. Handlers should send events until then and
+// close the stream. Register them with Router.SSE to exempt them from the request
+// timeout.
+//
+//	stream, err := doris.SSE(w, r)
+//	if err != nil {
+//	  return errors.Trace(err)
+//	}
+//	defer stream.Close()
+//	for {
+//	  select {
+//	  case <-stream.Context().Done():
+//	    return nil
+//	  case msg := <-updates:
+//	    if err := stream.Send(doris.SSEEvent{Data: msg}); err != nil {
+//	      return errors.Trace(err)
+//	    }
+//	  }
+//	}
+func SSE(w http.ResponseWriter, r *http.Request, opts ...SSEOption) (*SSEStream, error) {
+	stream := &SSEStream{
+		w:         w,
+		rc:        http.NewResponseController(w),
+		heartbeat: 15 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(stream)
+	}
+
+	stream.ctx, stream.cancel = context.WithCancel(r.Context())
+	if port := routeFromContext(r.Context()).port; port != nil && port.ctx != nil {
+		stop := context.AfterFunc(port.ctx, stream.cancel)
+		context.AfterFunc(stream.ctx, func() { stop() })
+	}
+
+	// Long lived responses should not be cut by the write timeout of the server.
+	_ = stream.rc.SetWriteDeadline(time.Time{})
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusOK)
+	if err := stream.rc.Flush(); err != nil {
+		stream.cancel()
+		return nil, errors.Errorf("cannot flush event stream: %w", err)
+	}
+
+	if registered, ok := r.Context().Value(sseStreamKey{}).(**SSEStream); ok {
+		*registered = stream
+	}
+	if stream.heartbeat > 0 {
+		go stream.keepAlive()
+	}
+
+	return stream, nil
+}
+
+// LastEventID returns the ID of the last event received by the client before
+// reconnecting, to resume the stream from there.
+func LastEventID(r *http.Request) string {
+	return r.Header.Get("Last-Event-ID")
+}
+
+// Context returns a context that is canceled when the client disconnects, the server
+// shuts down or the stream is closed.
+func (stream *SSEStream) Context() context.Context {
+	return stream.ctx
+}
+
+// Send writes an event to the client.
+func (stream *SSEStream) Send(event SSEEvent) error {
+	if strings.ContainsAny(event.ID, "\r\n") || strings.ContainsAny(event.Event, "\r\n") {
+		return errors.Errorf("event id and type cannot contain new lines")
+	}
+
+	var sb strings.Builder
+	if event.ID != "" {
+		fmt.Fprintf(&sb, "id: %s\n", event.ID)
+	}
+	if event.Event != "" {
+		fmt.Fprintf(&sb, "event: %s\n", event.Event)
+	}
+	if event.Retry > 0 {
+		fmt.Fprintf(&sb, "retry: %d\n", event.Retry.Milliseconds())
+	}
+	data := strings.ReplaceAll(event.Data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&sb, "data: %s\n", line)
+	}
+	sb.WriteString("\n")
+
+	return stream.write(sb.String())
+}
+
+// Close stops the stream. It does not close the connection; the handler should
+// return afterwards.
+func (stream *SSEStream) Close() {
+	stream.cancel()
+
+	// Wait for any heartbeat in progress before the handler releases the writer.
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+}
+
+func (stream *SSEStream) write(msg string) error {
+	stream.mu.Lock()
+	defer stream.mu.Unlock()
+
+	if stream.err != nil {
+		return stream.err
+	}
+	if err := stream.ctx.Err(); err != nil {
+		return errors.Trace(err)
+	}
+	if _, err := fmt.Fprint(stream.w, msg); err != nil {
+		stream.fail(err)
+		return stream.err
+	}
+	if err := stream.rc.Flush(); err != nil {
+		stream.fail(err)
+		return stream.err
+	}
+	return nil
+}
+
+// fail stops the stream after an error writing to the client.
+func (stream *SSEStream) fail(err error) {
+	stream.err = errors.Trace(err)
+	stream.cancel()
+}
+
+func (stream *SSEStream) keepAlive() {
+	ticker := time.NewTicker(stream.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stream.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := stream.write(": ping\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
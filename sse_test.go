@@ -0,0 +1,119 @@
+package doris
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSE(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	stream, err := SSE(w, r, WithSSEHeartbeat(0))
+	require.NoError(t, err)
+	defer stream.Close()
+
+	require.NoError(t, stream.Send(SSEEvent{ID: "1", Event: "update", Data: "foo\nbar"}))
+	require.NoError(t, stream.Send(SSEEvent{Data: "baz", Retry: 3 * time.Second}))
+	require.Error(t, stream.Send(SSEEvent{ID: "1\n2"}))
+
+	require.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	require.Equal(t, "id: 1\nevent: update\ndata: foo\ndata: bar\n\nretry: 3000\ndata: baz\n\n", w.Body.String())
+}
+
+func TestSSEServerShutdown(t *testing.T) {
+	server := NewServer()
+	closed := make(chan struct{})
+	server.Get("/events", func(w http.ResponseWriter, r *http.Request) error {
+		stream, err := SSE(w, r, WithSSEHeartbeat(0))
+		if err != nil {
+			return err
+		}
+		defer stream.Close()
+		<-stream.Context().Done()
+		close(closed)
+		return nil
+	})
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	resp, err := web.Client().Get(web.URL + "/events")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	server.cancel()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("stream not closed on shutdown")
+	}
+}
+
+func TestSSERoute(t *testing.T) {
+	server := NewServer()
+	var deadline bool
+	server.SSE("/events", func(w http.ResponseWriter, r *http.Request) error {
+		_, deadline = r.Context().Deadline()
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.False(t, deadline)
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events/foo", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSSERouteExact(t *testing.T) {
+	server := NewServer()
+	server.SSE("/events", func(w http.ResponseWriter, r *http.Request) error {
+		return nil
+	})
+	server.Get("/eventsX", func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/eventsX", nil))
+	require.Equal(t, http.StatusAccepted, w.Code)
+}
+
+func TestTimeoutEventStreamHeader(t *testing.T) {
+	// The header of the client does not exempt normal routes from the timeout.
+	var deadline bool
+	handler := timeoutMiddleware(&ServerPort{}, &route{timeout: time.Second}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, deadline = r.Context().Deadline()
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/event-stream")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	require.True(t, deadline)
+}
+
+func TestSSERouteClose(t *testing.T) {
+	server := NewServer()
+	var stream *SSEStream
+	server.SSE("/events", func(w http.ResponseWriter, r *http.Request) error {
+		var err error
+		stream, err = SSE(w, r, WithSSEHeartbeat(time.Millisecond))
+		return err
+	})
+
+	// The handler returns without closing the stream.
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Error(t, stream.Context().Err())
+
+	time.Sleep(10 * time.Millisecond)
+	require.Empty(t, w.Body.String())
+}
@@ -0,0 +1,222 @@
+package doris
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altipla-consulting/errors"
+)
+
+// StaticOption configures the static files served by a router.
+type StaticOption func(cnf *staticConfig)
+
+// WithSPAFallback serves the file (usually "index.html") for any route that does not
+// exist and does not look like a file, so a single page application can handle it.
+func WithSPAFallback(file string) StaticOption {
+	return func(cnf *staticConfig) {
+		cnf.fallback = file
+	}
+}
+
+// WithStaticMaxAge configures the time browsers can cache files that are not
+// fingerprinted. By default they have to revalidate them every time using the ETag.
+// Fingerprinted files (like "app.3f2a1b9c.js") are always cached for a year.
+func WithStaticMaxAge(maxAge time.Duration) StaticOption {
+	return func(cnf *staticConfig) {
+		cnf.maxAge = maxAge
+	}
+}
+
+type staticConfig struct {
+	fallback string
+	maxAge   time.Duration
+}
+
+// Static serves the files of fsys under the prefix. It is intended to serve assets
+// from an embed.FS, but any file system works.
+//
+// When the client accepts it, precompressed siblings of the files with the .br or
+// .gz extensions are sent instead of the original one. Directories serve their
+// index.html file. Files not found are rendered with the standard error page.
+func (r *Router) Static(prefix string, fsys fs.FS, opts ...StaticOption) {
+	cnf := new(staticConfig)
+	for _, opt := range opts {
+		opt(cnf)
+	}
+	s := &staticServer{
+		prefix: r.prefix + prefix,
+		fsys:   fsys,
+		cnf:    cnf,
+	}
+	r.PathPrefixHandlerHTTP(prefix, Handler(s.serve), staticRoute())
+}
+
+var (
+	staticPrecompressed = []struct {
+		encoding  string
+		extension string
+	}{
+		{"br", ".br"},
+		{"gzip", ".gz"},
+	}
+
+	// Fingerprints are a dash or dot followed by at least 8 alphanumeric characters
+	// just before the extension, as generated by the usual bundlers.
+	staticFingerprint = regexp.MustCompile(`[.-]([0-9a-zA-Z_]{8,})\.[a-zA-Z0-9]+$`)
+)
+
+type staticServer struct {
+	prefix string
+	fsys   fs.FS
+	cnf    *staticConfig
+
+	etags sync.Map
+}
+
+// isFingerprinted detects if the name contains a hash of the content. It requires
+// at least a digit in the hash to avoid confusing it with normal words.
+func isFingerprinted(name string) bool {
+	match := staticFingerprint.FindStringSubmatch(path.Base(name))
+	return match != nil && strings.ContainsAny(match[1], "0123456789")
+}
+
+type staticETagKey struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (s *staticServer) serve(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		Error(w, http.StatusMethodNotAllowed)
+		return nil
+	}
+
+	name, err := s.resolve(strings.TrimPrefix(r.URL.Path, s.prefix))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+			Error(w, http.StatusNotFound)
+			return nil
+		}
+		return errors.Errorf("cannot open static file %q: %w", name, err)
+	}
+
+	// Detect the content type from the original name before replacing it with a
+	// precompressed variant.
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+	switch {
+	case name != s.cnf.fallback && isFingerprinted(name):
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	case s.cnf.maxAge > 0:
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(s.cnf.maxAge.Seconds())))
+	default:
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	weights := parseAcceptEncoding(r.Header.Get("Accept-Encoding"))
+	for _, pre := range staticPrecompressed {
+		if acceptedWeight(weights, pre.encoding) <= 0 {
+			continue
+		}
+		if _, err := fs.Stat(s.fsys, name+pre.extension); err == nil {
+			name += pre.extension
+			w.Header().Set("Content-Encoding", pre.encoding)
+			break
+		}
+	}
+
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return errors.Errorf("cannot open static file %q: %w", name, err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return errors.Errorf("cannot stat static file %q: %w", name, err)
+	}
+	content, err := readSeeker(f)
+	if err != nil {
+		return errors.Errorf("cannot read static file %q: %w", name, err)
+	}
+	etag, err := s.etag(name, stat, content)
+	if err != nil {
+		return errors.Errorf("cannot compute etag of static file %q: %w", name, err)
+	}
+	w.Header().Set("ETag", etag)
+
+	http.ServeContent(w, r, "", stat.ModTime(), content)
+	return nil
+}
+
+// resolve finds the name of the file that should be served for the path, taking
+// into account the index of directories and the fallback of single page applications.
+func (s *staticServer) resolve(name string) (string, error) {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+
+	stat, err := fs.Stat(s.fsys, name)
+	if errors.Is(err, fs.ErrNotExist) && s.cnf.fallback != "" && path.Ext(name) == "" {
+		name = s.cnf.fallback
+		stat, err = fs.Stat(s.fsys, name)
+	}
+	if err != nil {
+		return name, err
+	}
+	if stat.IsDir() {
+		name = path.Join(name, "index.html")
+		if _, err := fs.Stat(s.fsys, name); err != nil {
+			return name, err
+		}
+	}
+	return name, nil
+}
+
+// readSeeker returns the file itself if it can seek, like the files of an embed.FS,
+// or reads it to memory otherwise.
+func readSeeker(f fs.File) (io.ReadSeeker, error) {
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(content), nil
+}
+
+// etag computes a strong ETag with the hash of the content. It is cached while
+// the file does not change its size or modification time.
+func (s *staticServer) etag(name string, stat fs.FileInfo, content io.ReadSeeker) (string, error) {
+	key := staticETagKey{name, stat.Size(), stat.ModTime()}
+	if etag, ok := s.etags.Load(key); ok {
+		return etag.(string), nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	s.etags.Store(key, etag)
+	return etag, nil
+}
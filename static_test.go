@@ -0,0 +1,121 @@
+package doris
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStatic(opts ...StaticOption) *staticServer {
+	cnf := new(staticConfig)
+	for _, opt := range opts {
+		opt(cnf)
+	}
+	return &staticServer{
+		prefix: "/static/",
+		fsys: fstest.MapFS{
+			"index.html":          {Data: []byte("<p>index</p>")},
+			"css/app.css":         {Data: []byte("body{}")},
+			"css/app.css.br":      {Data: []byte("brotli body")},
+			"js/app.3f2a1b9c.js":  {Data: []byte("alert(1)")},
+			"docs/index.html":     {Data: []byte("<p>docs</p>")},
+			"images/logo-big.svg": {Data: []byte("<svg></svg>")},
+		},
+		cnf: cnf,
+	}
+}
+
+func serveStatic(s *staticServer, path string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	Handler(s.serve).ServeHTTP(w, r)
+	return w
+}
+
+func TestStaticFile(t *testing.T) {
+	w := serveStatic(newTestStatic(), "/static/css/app.css", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "body{}", w.Body.String())
+	require.Equal(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	require.NotEmpty(t, w.Header().Get("ETag"))
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	w := serveStatic(newTestStatic(), "/static/css/app.css", http.Header{"Accept-Encoding": {"gzip, br"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "brotli body", w.Body.String())
+	require.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	require.Equal(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestStaticNotModified(t *testing.T) {
+	s := newTestStatic()
+	w := serveStatic(s, "/static/css/app.css", nil)
+	w = serveStatic(s, "/static/css/app.css", http.Header{"If-None-Match": {w.Header().Get("ETag")}})
+	require.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestStaticFingerprinted(t *testing.T) {
+	w := serveStatic(newTestStatic(), "/static/js/app.3f2a1b9c.js", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "public, max-age=31536000, immutable", w.Header().Get("Cache-Control"))
+
+	w = serveStatic(newTestStatic(), "/static/images/logo-big.svg", nil)
+	require.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+}
+
+func TestStaticIndex(t *testing.T) {
+	w := serveStatic(newTestStatic(), "/static/docs/", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "<p>docs</p>", w.Body.String())
+}
+
+func TestStaticNotFound(t *testing.T) {
+	w := serveStatic(newTestStatic(), "/static/foo/bar", nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Contains(t, w.Body.String(), "Página no encontrada")
+}
+
+func TestStaticSPAFallback(t *testing.T) {
+	s := newTestStatic(WithSPAFallback("index.html"))
+
+	w := serveStatic(s, "/static/foo/bar", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "<p>index</p>", w.Body.String())
+
+	w = serveStatic(s, "/static/foo/bar.js", nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStaticMethodNotAllowed(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/static/css/app.css", nil)
+	w := httptest.NewRecorder()
+	Handler(newTestStatic().serve).ServeHTTP(w, r)
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	require.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+	require.Contains(t, w.Body.String(), "Método no permitido")
+}
+
+type brokenFS struct{}
+
+func (brokenFS) Open(name string) (fs.File, error) {
+	return nil, errors.New("disk failure")
+}
+
+func TestStaticFilesystemError(t *testing.T) {
+	records := captureLogs(t)
+	s := &staticServer{prefix: "/static/", fsys: brokenFS{}, cnf: new(staticConfig)}
+	w := serveStatic(s, "/static/css/app.css", nil)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NotEmpty(t, *records)
+	require.Equal(t, "Handler failed", (*records)[0].Message)
+}
@@ -0,0 +1,102 @@
+package doris
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/altipla-consulting/errors"
+)
+
+// DefaultTimeout is the maximum duration of a request if no other timeout is configured.
+// It is a little bit smaller than the usual 30 seconds of load balancers to have time
+// to answer with a proper error.
+const DefaultTimeout = 29 * time.Second
+
+var errRequestTimeout = errors.New("doris: request timeout")
+
+func withRequestTimeout(r *http.Request, timeout time.Duration) (*http.Request, context.CancelFunc) {
+	ctx, cancel := context.WithTimeoutCause(r.Context(), timeout, errRequestTimeout)
+	return r.WithContext(ctx), cancel
+}
+
+// logRequestTimeout emits a log and a metric if the timeout of the request fired.
+func logRequestTimeout(r *http.Request, port string, rt *route) {
+	if context.Cause(r.Context()) != errRequestTimeout {
+		return
+	}
+	logger(r.Context()).Warn("Request timed out",
+		slog.String("url", r.URL.String()),
+		slog.String("route", rt.pattern),
+		slog.Duration("timeout", rt.timeout),
+		slog.String("client-ip", ClientIP(r.Context())))
+	metrics.GetOrCreateCounter(fmt.Sprintf(`doris_http_timeouts_total{port=%q,route=%q}`, port, rt.pattern)).Inc()
+}
+
+// procedureTimeouts applies the timeout of each Connect procedure. Streaming
+// procedures are usually long lived, so they only have a timeout if they have a
+// custom one.
+type procedureTimeouts struct {
+	timeout    time.Duration
+	procedures map[string]time.Duration
+}
+
+func timeoutInterceptor(timeout time.Duration, procedures map[string]time.Duration) connect.Interceptor {
+	return &procedureTimeouts{
+		timeout:    timeout,
+		procedures: procedures,
+	}
+}
+
+func (pt *procedureTimeouts) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return connect.UnaryFunc(func(ctx context.Context, in connect.AnyRequest) (connect.AnyResponse, error) {
+		d := pt.timeout
+		if custom, ok := pt.procedures[in.Spec().Procedure]; ok {
+			d = custom
+		}
+		if d <= 0 {
+			return next(ctx, in)
+		}
+
+		ctx, cancel := context.WithTimeoutCause(ctx, d, errRequestTimeout)
+		defer cancel()
+		reply, err := next(ctx, in)
+		logProcedureTimeout(ctx, in.Spec().Procedure, d)
+		return reply, err
+	})
+}
+
+func (pt *procedureTimeouts) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (pt *procedureTimeouts) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return connect.StreamingHandlerFunc(func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		d := pt.procedures[conn.Spec().Procedure]
+		if d <= 0 {
+			return next(ctx, conn)
+		}
+
+		ctx, cancel := context.WithTimeoutCause(ctx, d, errRequestTimeout)
+		defer cancel()
+		err := next(ctx, conn)
+		logProcedureTimeout(ctx, conn.Spec().Procedure, d)
+		return err
+	})
+}
+
+// logProcedureTimeout emits a log and a metric if the timeout of the call fired.
+func logProcedureTimeout(ctx context.Context, procedure string, timeout time.Duration) {
+	if context.Cause(ctx) != errRequestTimeout {
+		return
+	}
+	logger(ctx).Warn("Connect call timed out",
+		slog.String("method", procedure),
+		slog.Duration("timeout", timeout),
+		slog.String("client-ip", ClientIP(ctx)))
+	metrics.GetOrCreateCounter(fmt.Sprintf(`doris_connect_timeouts_total{procedure=%q}`, procedure)).Inc()
+}
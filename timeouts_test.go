@@ -0,0 +1,122 @@
+package doris
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRouteTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []Option
+		route    []RouteOption
+		deadline time.Duration
+	}{
+		{name: "default", deadline: DefaultTimeout},
+		{name: "server", opts: []Option{WithTimeout(time.Minute)}, deadline: time.Minute},
+		{name: "route", opts: []Option{WithTimeout(time.Minute)}, route: []RouteOption{WithRouteTimeout(time.Hour)}, deadline: time.Hour},
+		{name: "disabled server", opts: []Option{WithTimeout(0)}},
+		{name: "disabled route", route: []RouteOption{WithRouteTimeout(0)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := NewServer(test.opts...)
+			var deadline time.Time
+			var ok bool
+			server.Handle(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				deadline, ok = r.Context().Deadline()
+			}), test.route...)
+
+			start := time.Now()
+			server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			if test.deadline == 0 {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.WithinDuration(t, start.Add(test.deadline), deadline, time.Second)
+		})
+	}
+}
+
+func TestRouteTimeoutExpired(t *testing.T) {
+	records := captureLogs(t)
+	server := NewServer(WithTimeout(10 * time.Millisecond))
+	server.Handle(Handler(func(w http.ResponseWriter, r *http.Request) error {
+		<-r.Context().Done()
+		return r.Context().Err()
+	}))
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	var logged bool
+	for _, record := range *records {
+		logged = logged || record.Message == "Request timed out"
+	}
+	require.True(t, logged)
+}
+
+func TestTimeoutInterceptor(t *testing.T) {
+	interceptor := connect.WithInterceptors(timeoutInterceptor(time.Minute, map[string]time.Duration{
+		"/test.Echo/Custom":       time.Hour,
+		"/test.Echo/Disabled":     0,
+		"/test.Echo/StreamCustom": time.Hour,
+	}))
+	deadlines := make(map[string]time.Duration)
+	record := func(ctx context.Context, procedure string) {
+		deadlines[procedure] = 0
+		if deadline, ok := ctx.Deadline(); ok {
+			deadlines[procedure] = time.Until(deadline).Round(time.Minute)
+		}
+	}
+	unary := func(ctx context.Context, in *connect.Request[wrapperspb.StringValue]) (*connect.Response[wrapperspb.StringValue], error) {
+		record(ctx, in.Spec().Procedure)
+		return connect.NewResponse(in.Msg), nil
+	}
+	stream := func(ctx context.Context, in *connect.Request[wrapperspb.StringValue], stream *connect.ServerStream[wrapperspb.StringValue]) error {
+		record(ctx, in.Spec().Procedure)
+		return stream.Send(in.Msg)
+	}
+
+	mux := http.NewServeMux()
+	for _, procedure := range []string{"/test.Echo/Default", "/test.Echo/Custom", "/test.Echo/Disabled"} {
+		mux.Handle(procedure, connect.NewUnaryHandler(procedure, unary, interceptor))
+	}
+	for _, procedure := range []string{"/test.Echo/Stream", "/test.Echo/StreamCustom"} {
+		mux.Handle(procedure, connect.NewServerStreamHandler(procedure, stream, interceptor))
+	}
+	web := httptest.NewServer(mux)
+	defer web.Close()
+
+	for _, procedure := range []string{"/test.Echo/Default", "/test.Echo/Custom", "/test.Echo/Disabled"} {
+		client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](web.Client(), web.URL+procedure)
+		_, err := client.CallUnary(context.Background(), connect.NewRequest(wrapperspb.String("foo")))
+		require.NoError(t, err)
+	}
+	for _, procedure := range []string{"/test.Echo/Stream", "/test.Echo/StreamCustom"} {
+		client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](web.Client(), web.URL+procedure)
+		stream, err := client.CallServerStream(context.Background(), connect.NewRequest(wrapperspb.String("foo")))
+		require.NoError(t, err)
+		for stream.Receive() {
+		}
+		require.NoError(t, stream.Err())
+		require.NoError(t, stream.Close())
+	}
+
+	require.Equal(t, map[string]time.Duration{
+		"/test.Echo/Default":      time.Minute,
+		"/test.Echo/Custom":       time.Hour,
+		"/test.Echo/Disabled":     0,
+		"/test.Echo/Stream":       0,
+		"/test.Echo/StreamCustom": time.Hour,
+	}, deadlines)
+}
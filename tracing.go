@@ -0,0 +1,241 @@
+package doris
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/altipla-consulting/env"
+	"github.com/altipla-consulting/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/altipla-consulting/doris"
+
+// tracePropagator reads and writes the W3C traceparent and tracestate headers.
+var tracePropagator = propagation.TraceContext{}
+
+// TracingOption configures the export of the traces of the application.
+type TracingOption func(cnf *tracingConfig)
+
+// WithTracingStdout writes the spans to the standard output. It is useful to debug
+// the traces locally.
+func WithTracingStdout() TracingOption {
+	return func(cnf *tracingConfig) {
+		cnf.stdout = true
+	}
+}
+
+// WithTracingOTLP sends the spans to an OpenTelemetry collector using OTLP over HTTP,
+// for example "http://localhost:4318". An empty endpoint uses the standard
+// OTEL_EXPORTER_OTLP_ENDPOINT environment variables.
+func WithTracingOTLP(endpoint string) TracingOption {
+	return func(cnf *tracingConfig) {
+		cnf.otlp = true
+		cnf.endpoint = endpoint
+	}
+}
+
+// WithTracingSampleRatio configures the fraction of the traces started by this
+// application that are recorded, from 0 to 1. Traces started by other services keep
+// the decision of their parent if they come from trusted proxies (see
+// WithTrustedProxies); the rest start a new trace linked to the one of the client,
+// as any client could force the recording otherwise. By default all the traces are
+// recorded.
+func WithTracingSampleRatio(ratio float64) TracingOption {
+	return func(cnf *tracingConfig) {
+		cnf.ratio = ratio
+	}
+}
+
+type tracingConfig struct {
+	stdout   bool
+	otlp     bool
+	endpoint string
+	ratio    float64
+}
+
+func newTracerProvider(ctx context.Context, cnf *tracingConfig) (*sdktrace.TracerProvider, error) {
+	res := resource.NewSchemaless(
+		attribute.String("service.name", env.ServiceName()),
+		attribute.String("service.version", env.Version()),
+	)
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cnf.ratio))),
+	}
+
+	if cnf.stdout {
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	if cnf.otlp {
+		var exporterOpts []otlptracehttp.Option
+		if cnf.endpoint != "" {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithEndpointURL(cnf.endpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+func tracingMiddleware(sp *ServerPort, rt *route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var trusted bool
+		if peer, ok := parseHopAddr(r.RemoteAddr); ok && sp != nil {
+			trusted = containsAddr(sp.trustedProxies, peer)
+		}
+		ctx, parent := extractTraceParent(r.Context(), r.Header, trusted)
+		name := r.Method
+		if rt.pattern != "" {
+			name += " " + rt.pattern
+		}
+		opts := append(parent,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", rt.pattern),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("client.address", ClientIP(r.Context())),
+				attribute.String("user_agent.original", r.UserAgent()),
+			))
+		ctx, span := tracer().Start(ctx, name, opts...)
+		defer span.End()
+
+		rw := trackResponse(w)
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("http.response.status_code", rw.Status()))
+		if rw.Status() >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(rw.Status()))
+		}
+	})
+}
+
+func tracingInterceptor() connect.Interceptor {
+	return connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return connect.UnaryFunc(func(ctx context.Context, in connect.AnyRequest) (connect.AnyResponse, error) {
+			// Calls served through the router already have the span of the request.
+			if sc := trace.SpanContextFromContext(ctx); sc.IsValid() && !sc.IsRemote() {
+				ctx, span := startProcedureSpan(ctx, in.Spec().Procedure, trace.SpanKindInternal)
+				defer span.End()
+
+				reply, err := next(ctx, in)
+				endProcedureSpan(span, err)
+				return reply, err
+			}
+
+			// Requests not served through the router need to read the parent here. Without
+			// the list of trusted proxies the parent is never trusted.
+			ctx, parent := extractTraceParent(ctx, in.Header(), false)
+			ctx, span := startProcedureSpan(ctx, in.Spec().Procedure, trace.SpanKindServer, parent...)
+			defer span.End()
+
+			reply, err := next(ctx, in)
+			endProcedureSpan(span, err)
+			return reply, err
+		})
+	})
+}
+
+// TraceInterceptor propagates the trace of the context to the called service with
+// the W3C traceparent header, in a new span that measures the call.
+func TraceInterceptor() connect.Interceptor {
+	return connect.UnaryInterceptorFunc(
+		func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+				ctx, span := startProcedureSpan(ctx, req.Spec().Procedure, trace.SpanKindClient)
+				defer span.End()
+
+				tracePropagator.Inject(ctx, propagation.HeaderCarrier(req.Header()))
+				reply, err := next(ctx, req)
+				endProcedureSpan(span, err)
+				return reply, err
+			}
+		},
+	)
+}
+
+// extractTraceParent reads the W3C traceparent header of the request. Only trusted
+// peers can continue their trace, as the header also decides if the trace is sampled
+// and any client could force the recording of all its requests otherwise. The rest
+// start a new trace linked to the one of the header.
+func extractTraceParent(ctx context.Context, header http.Header, trusted bool) (context.Context, []trace.SpanStartOption) {
+	remote := trace.SpanContextFromContext(tracePropagator.Extract(context.Background(), propagation.HeaderCarrier(header)))
+	if !remote.IsValid() {
+		return ctx, nil
+	}
+	if trusted {
+		return trace.ContextWithRemoteSpanContext(ctx, remote), nil
+	}
+	return ctx, []trace.SpanStartOption{trace.WithLinks(trace.Link{SpanContext: remote})}
+}
+
+func startProcedureSpan(ctx context.Context, procedure string, kind trace.SpanKind, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(procedure, "/"), "/")
+	opts = append(opts,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "connect_rpc"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", method),
+		))
+	return tracer().Start(ctx, strings.TrimPrefix(procedure, "/"), opts...)
+}
+
+func endProcedureSpan(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	code := connect.CodeOf(err)
+	span.SetAttributes(attribute.String("rpc.connect_rpc.error_code", code.String()))
+	switch code {
+	case connect.CodeUnknown, connect.CodeInternal, connect.CodeDataLoss, connect.CodeUnavailable, connect.CodeDeadlineExceeded, connect.CodeUnimplemented:
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// traceAttrs returns the attributes to correlate a log record with its trace.
+func traceAttrs(ctx context.Context) []any {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []any{
+		"trace-id", sc.TraceID().String(),
+		"span-id", sc.SpanID().String(),
+	}
+}
+
+func (server *Server) configureTracing(cnf *tracingConfig) error {
+	provider, err := newTracerProvider(server.ctx, cnf)
+	if err != nil {
+		return errors.Errorf("doris: cannot configure tracing: %w", err)
+	}
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(tracePropagator, propagation.Baggage{}))
+	server.tracerProvider = provider
+	return nil
+}
@@ -0,0 +1,115 @@
+package doris
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// recordSpans replaces the global tracer provider during the test to inspect the spans.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.NeverSample())),
+		sdktrace.WithSpanProcessor(recorder),
+	))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return recorder
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := recordSpans(t)
+	sp := &ServerPort{trustedProxies: parsePrefixes([]string{"192.0.2.1"})}
+
+	var sc trace.SpanContext
+	handler := tracingMiddleware(sp, &route{pattern: "/foo"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc = trace.SpanContextFromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	require.Len(t, recorder.Ended(), 1)
+}
+
+func TestTracingMiddlewareUntrustedParent(t *testing.T) {
+	recorder := recordSpans(t)
+	sp := &ServerPort{trustedProxies: parsePrefixes([]string{"10.0.0.0/8"})}
+
+	var sc trace.SpanContext
+	handler := tracingMiddleware(sp, &route{pattern: "/foo"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc = trace.SpanContextFromContext(r.Context())
+	}))
+
+	// The client cannot force the sampling of the request, the trace is only linked.
+	r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	require.NotEqual(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	require.False(t, sc.IsSampled())
+	require.Empty(t, recorder.Ended())
+}
+
+func TestTracingInterceptorNested(t *testing.T) {
+	recorder := recordSpans(t)
+	sp := &ServerPort{trustedProxies: parsePrefixes([]string{"127.0.0.1"})}
+
+	echo := connect.NewUnaryHandler("/test.Echo/Echo", echoProcedure, connect.WithInterceptors(tracingInterceptor()))
+	web := httptest.NewServer(tracingMiddleware(sp, &route{pattern: "/test.Echo/"}, echo))
+	defer web.Close()
+
+	client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](web.Client(), web.URL+"/test.Echo/Echo")
+	req := connect.NewRequest(wrapperspb.String("foo"))
+	req.Header().Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, err := client.CallUnary(context.Background(), req)
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "test.Echo/Echo", spans[0].Name())
+	require.Equal(t, trace.SpanKindInternal, spans[0].SpanKind())
+	require.Equal(t, trace.SpanKindServer, spans[1].SpanKind())
+	require.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+}
+
+func TestTraceAttrs(t *testing.T) {
+	require.Empty(t, traceAttrs(context.Background()))
+
+	header := http.Header{"Traceparent": []string{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+	ctx := tracePropagator.Extract(context.Background(), propagation.HeaderCarrier(header))
+	require.Equal(t, []any{"trace-id", "4bf92f3577b34da6a3ce929d0e0e4736", "span-id", "00f067aa0ba902b7"}, traceAttrs(ctx))
+}
+
+func TestTracingOTLP(t *testing.T) {
+	var received atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/traces", r.URL.Path)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NotEmpty(t, body)
+		received.Add(1)
+	}))
+	defer collector.Close()
+
+	provider, err := newTracerProvider(context.Background(), &tracingConfig{otlp: true, endpoint: collector.URL, ratio: 1})
+	require.NoError(t, err)
+
+	_, span := provider.Tracer(tracerName).Start(context.Background(), "foo")
+	span.End()
+	require.NoError(t, provider.Shutdown(context.Background()))
+	require.EqualValues(t, 1, received.Load())
+}
@@ -0,0 +1,245 @@
+package doris
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/altipla-consulting/errors"
+	"github.com/altipla-consulting/telemetry"
+	"github.com/coder/websocket"
+)
+
+// WebSocketHandler serves a single WebSocket connection. The context is canceled
+// when the server shuts down; the connection is closed when the function returns.
+type WebSocketHandler func(ctx context.Context, conn *websocket.Conn) error
+
+// WebSocketOption configures a WebSocket endpoint.
+type WebSocketOption func(cnf *webSocketConfig)
+
+// WithWebSocketOrigins authorizes other origins to open connections, with host
+// patterns like "example.com" or "*.example.com". By default only the same origin
+// of the application is allowed.
+func WithWebSocketOrigins(patterns ...string) WebSocketOption {
+	return func(cnf *webSocketConfig) {
+		cnf.origins = append(cnf.origins, patterns...)
+	}
+}
+
+// WithWebSocketReadLimit changes the maximum size of the messages received from the
+// client. By default it is 32 KB.
+func WithWebSocketReadLimit(limit int64) WebSocketOption {
+	return func(cnf *webSocketConfig) {
+		cnf.readLimit = limit
+	}
+}
+
+// WithWebSocketPingInterval changes the interval between the pings that keep the
+// connection alive and detect dead clients. By default it is 30 seconds. A zero
+// interval disables them.
+func WithWebSocketPingInterval(interval time.Duration) WebSocketOption {
+	return func(cnf *webSocketConfig) {
+		cnf.ping = interval
+	}
+}
+
+type webSocketConfig struct {
+	origins   []string
+	readLimit int64
+	ping      time.Duration
+}
+
+// WebSocket registers a WebSocket endpoint in the path. Connections are exempted
+// from the request timeout and receive a close frame when the server shuts down.
+//
+// The handler should read from the connection to process the control frames of the
+// protocol, as required by github.com/coder/websocket.
+func (r *Router) WebSocket(path string, handler WebSocketHandler, opts ...WebSocketOption) {
+	cnf := &webSocketConfig{
+		readLimit: 32 << 10,
+		ping:      30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(cnf)
+	}
+	ws := &webSocketServer{
+		path:    r.prefix + path,
+		cnf:     cnf,
+		handler: handler,
+		port:    r.port,
+	}
+	r.Server.Get(r.prefix+path, r.routeHandler(path, ws, []RouteOption{WithRouteTimeout(0), WithRouteMaxBodySize(0), streamingRoute()}))
+}
+
+type webSocketServer struct {
+	path    string
+	cnf     *webSocketConfig
+	handler WebSocketHandler
+	port    *ServerPort
+}
+
+func (ws *webSocketServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Check the origin against the host of the client when behind a proxy.
+	if host := RequestHost(r.Context()); host != "" && host != r.Host {
+		r = r.Clone(r.Context())
+		r.Host = host
+	}
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		OriginPatterns: ws.cnf.origins,
+	})
+	if err != nil {
+		// Accept already answered the request with the error.
+		logger(r.Context()).Warn("Cannot accept WebSocket connection",
+			slog.String("error", err.Error()),
+			slog.String("origin", r.Header.Get("Origin")),
+			slog.String("client-ip", ClientIP(r.Context())))
+		return
+	}
+	conn.SetReadLimit(ws.cnf.readLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	if ws.port.ctx != nil {
+		// Send the close frame before canceling the context, as canceling a read in
+		// progress closes the connection abruptly.
+		stop := context.AfterFunc(ws.port.ctx, func() {
+			_ = conn.Close(websocket.StatusGoingAway, "server shutting down")
+			cancel()
+		})
+		defer stop()
+	}
+
+	done, ok := ws.port.sockets.add(conn)
+	if !ok {
+		_ = conn.Close(websocket.StatusGoingAway, "server shutting down")
+		return
+	}
+	defer done()
+	gauge := metrics.GetOrCreateGauge(fmt.Sprintf(`doris_websocket_connections{port=%q,route=%q}`, ws.port.port, ws.path), nil)
+	gauge.Inc()
+	defer gauge.Dec()
+
+	if ws.cnf.ping > 0 {
+		go keepAliveWebSocket(ctx, conn, ws.cnf.ping)
+	}
+
+	if err := ws.serve(ctx, conn); err != nil {
+		logger(r.Context()).Error("WebSocket handler failed",
+			slog.String("error", err.Error()),
+			slog.String("details", errors.Details(err)),
+			slog.String("url", r.URL.String()),
+			slog.String("client-ip", ClientIP(r.Context())))
+		telemetry.ReportError(r.Context(), err)
+		_ = conn.Close(websocket.StatusInternalError, "internal error")
+		return
+	}
+	if ws.shuttingDown() {
+		_ = conn.Close(websocket.StatusGoingAway, "server shutting down")
+		return
+	}
+	_ = conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// serve runs the handler ignoring the errors of connections closed by the client
+// or by the shutdown of the server.
+func (ws *webSocketServer) serve(ctx context.Context, conn *websocket.Conn) (reterr error) {
+	defer func() {
+		if rec := errors.Recover(recover()); rec != nil {
+			reterr = rec
+		}
+	}()
+
+	err := ws.handler(ctx, conn)
+	if err == nil || ctx.Err() != nil || ws.shuttingDown() {
+		return nil
+	}
+	switch websocket.CloseStatus(err) {
+	case websocket.StatusNormalClosure, websocket.StatusGoingAway, websocket.StatusNoStatusRcvd:
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+func (ws *webSocketServer) shuttingDown() bool {
+	return ws.port.ctx != nil && ws.port.ctx.Err() != nil
+}
+
+func keepAliveWebSocket(ctx context.Context, conn *websocket.Conn, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingctx, cancel := context.WithTimeout(ctx, interval/2)
+			err := conn.Ping(pingctx)
+			cancel()
+			if err != nil {
+				if ctx.Err() == nil {
+					_ = conn.Close(websocket.StatusPolicyViolation, "ping timeout")
+				}
+				return
+			}
+		}
+	}
+}
+
+// socketTracker keeps the open connections of a port to close them when the server
+// shuts down, as net/http does not track hijacked connections.
+type socketTracker struct {
+	mu     sync.Mutex
+	conns  map[*websocket.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// add registers a new connection. It returns false after closing all of them, the
+// connection should be rejected then.
+func (tracker *socketTracker) add(conn *websocket.Conn) (func(), bool) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if tracker.closed {
+		return nil, false
+	}
+	if tracker.conns == nil {
+		tracker.conns = make(map[*websocket.Conn]struct{})
+	}
+	tracker.conns[conn] = struct{}{}
+	tracker.wg.Add(1)
+
+	return func() {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		delete(tracker.conns, conn)
+		tracker.wg.Done()
+	}, true
+}
+
+// closeAll sends a close frame to all the open connections and waits for their
+// handlers to finish until the context is canceled. New connections are rejected
+// afterwards.
+func (tracker *socketTracker) closeAll(ctx context.Context) {
+	tracker.mu.Lock()
+	tracker.closed = true
+	for conn := range tracker.conns {
+		go conn.Close(websocket.StatusGoingAway, "server shutting down")
+	}
+	tracker.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		tracker.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
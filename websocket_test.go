@@ -0,0 +1,125 @@
+package doris
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func echoWebSocket(ctx context.Context, conn *websocket.Conn) error {
+	for {
+		typ, msg, err := conn.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if err := conn.Write(ctx, typ, msg); err != nil {
+			return err
+		}
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	server := NewServer()
+	server.WebSocket("/ws", echoWebSocket)
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(web.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte("hello")))
+	_, msg, err := conn.Read(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(msg))
+
+	require.NoError(t, conn.Close(websocket.StatusNormalClosure, ""))
+}
+
+func TestWebSocketShutdown(t *testing.T) {
+	server := NewServer()
+	server.WebSocket("/ws", echoWebSocket)
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(web.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	// Wait until the server registers the connection.
+	require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte("hello")))
+	_, _, err = conn.Read(ctx)
+	require.NoError(t, err)
+
+	// The client must be reading to answer the close handshake of the server.
+	errs := make(chan error, 1)
+	go func() {
+		_, _, err := conn.Read(ctx)
+		errs <- err
+	}()
+
+	server.cancel()
+	server.sockets.closeAll(ctx)
+
+	require.Equal(t, websocket.StatusGoingAway, websocket.CloseStatus(<-errs))
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	server := NewServer()
+	server.WebSocket("/ws", echoWebSocket)
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, resp, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(web.URL, "http")+"/ws", &websocket.DialOptions{
+		HTTPHeader: map[string][]string{"Origin": {"https://evil.example.com"}},
+	})
+	require.Error(t, err)
+	require.Equal(t, 403, resp.StatusCode)
+}
+
+func TestWebSocketAfterShutdown(t *testing.T) {
+	server := NewServer()
+	server.WebSocket("/ws", echoWebSocket)
+	web := httptest.NewServer(server)
+	defer web.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.sockets.closeAll(ctx)
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(web.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.CloseNow()
+
+	_, _, err = conn.Read(ctx)
+	require.Equal(t, websocket.StatusGoingAway, websocket.CloseStatus(err))
+}
+
+func TestWebSocketExactRoute(t *testing.T) {
+	server := NewServer()
+	server.WebSocket("/ws", echoWebSocket)
+	server.Get("/wsX", func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/wsX", nil))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ws/foo", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}